@@ -1,34 +1,110 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/terraform-tools/simple-tfswitch/pkg"
+	"github.com/terraform-tools/simple-tfswitch/pkg/cli"
 	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
 )
 
 const (
-	mirrorURL = "https://releases.hashicorp.com/terraform"
+	exitError = 1
+	exitUsage = 2
 )
 
+// version : set at build time with -ldflags "-X main.version=..."
+var version = "dev" //nolint:gochecknoglobals // set by the linker
+
 func main() {
 	args := os.Args
-	dir, err := os.Getwd()
 
 	logger.Setup()
 
+	dir, err := os.Getwd()
 	if err != nil {
 		log.Errorf("Failed to get current directory %v", err)
-		os.Exit(1)
+		exit(exitError)
+	}
+
+	// terraform -chdir=<dir> reads its configuration from <dir>, so must we
+	passThrough := cli.IsPassThrough(args[0])
+	if passThrough {
+		dir = pkg.TerraformWorkingDir(dir, args[1:])
+	}
+
+	if err := pkg.LoadConfig(dir); err != nil {
+		exit(errorExitCode(err))
+	}
+
+	// then log as configured, to a file for a log shipper
+	if err := logger.Configure(pkg.LogOptions()); err != nil {
+		exit(errorExitCode(err))
+	}
+
+	// invoked as simple-tfswitch: management subcommands
+	if !passThrough {
+		product := pkg.CurrentProduct()
+		src, err := pkg.NewMirrors(product.Mirrors(), product)
+		if err != nil {
+			exit(errorExitCode(err))
+		}
+
+		exit(errorExitCode(cli.Run(args[1:], cli.Options{
+			Version: version,
+			Source:  src,
+			Stdout:  os.Stdout,
+			Stderr:  os.Stderr,
+		})))
+	}
+
+	// invoked as tofu, OpenTofu runs anywhere, invoked as terraform, the directory chooses,
+	// the rest of the process and terraform itself then stick to this product
+	product := pkg.PassThroughProduct(args[0], dir)
+	if err := pkg.UseProduct(product); err != nil {
+		exit(errorExitCode(err))
+	}
+
+	src, err := pkg.NewMirrors(product.Mirrors(), product)
+	if err != nil {
+		exit(errorExitCode(err))
 	}
 
-	tfBinaryPath, err := pkg.InstallTFProvidedModule(dir, mirrorURL)
+	exitCode, err := pkg.RunTFProvidedModule(dir, src, args[1:]...)
 	if err != nil {
+		exit(errorExitCode(err))
+	}
+	exit(exitCode)
+}
+
+// exit : end the process with code, once the cache refreshes running in the background are over
+func exit(code int) {
+	pkg.WaitRefreshes()
+	os.Exit(code)
+}
+
+// errorExitCode : report err and map it to the exit code of the process, the only place errors end the process
+func errorExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, cli.ErrUsage):
+		// the usage has already been printed
+		return exitUsage
+	case errors.Is(err, pkg.ErrLockTimeout):
+		log.Errorln("Another simple-tfswitch is installing the same version:", err)
+	case errors.Is(err, pkg.ErrChecksum):
+		log.Errorln("Refusing an unverified download:", err)
+	case errors.Is(err, pkg.ErrOffline):
+		log.Errorln("Not available offline:", err)
+	case errors.Is(err, pkg.ErrConfig):
+		log.Errorln("Invalid configuration file:", err)
+	default:
 		log.Errorln("Error occurred:", err)
 	}
 
-	exitCode := pkg.RunTerraform(tfBinaryPath, args[1:]...)
-	os.Exit(exitCode)
+	return exitError
 }
@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const chdirFlag = "chdir"
+
+// ChdirFromArgs : the directory given to terraform with -chdir=<dir> or -chdir <dir>, empty when not set.
+// Like terraform, only the global options before the subcommand are looked at.
+func ChdirFromArgs(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			// the subcommand, options after it belong to it
+			return ""
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if value := strings.TrimPrefix(name, chdirFlag+"="); value != name {
+			return value
+		}
+
+		if name == chdirFlag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return ""
+}
+
+// TerraformWorkingDir : the directory terraform will run in when started from cwd with args
+func TerraformWorkingDir(cwd string, args []string) string {
+	chdir := ChdirFromArgs(args)
+	if chdir == "" {
+		return cwd
+	}
+
+	if filepath.IsAbs(chdir) {
+		return chdir
+	}
+
+	return filepath.Join(cwd, chdir)
+}
@@ -0,0 +1,49 @@
+package pkg_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestChdirFromArgs : only the -chdir global option before the subcommand is used
+func TestChdirFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"plan"}, ""},
+		{[]string{}, ""},
+		{[]string{"-chdir=envs/prod", "plan", "-out=plan"}, "envs/prod"},
+		{[]string{"--chdir=envs/prod", "plan"}, "envs/prod"},
+		{[]string{"-chdir", "envs/prod", "plan"}, "envs/prod"},
+		{[]string{"-no-color", "-chdir=envs/prod", "apply"}, "envs/prod"},
+		{[]string{"plan", "-chdir=envs/prod"}, ""},
+		{[]string{"-chdir"}, ""},
+	}
+
+	for _, test := range tests {
+		if chdir := pkg.ChdirFromArgs(test.args); chdir != test.expected {
+			t.Errorf("Expected %q for %v, got %q (unexpected)", test.expected, test.args, chdir)
+		}
+	}
+}
+
+// TestTerraformWorkingDir : -chdir is relative to the current directory unless absolute
+func TestTerraformWorkingDir(t *testing.T) {
+	cwd := filepath.Join(string(filepath.Separator), "repo")
+	abs := filepath.Join(string(filepath.Separator), "other")
+
+	if dir := pkg.TerraformWorkingDir(cwd, []string{"plan"}); dir != cwd {
+		t.Errorf("Expected %s, got %s (unexpected)", cwd, dir)
+	}
+
+	if dir := pkg.TerraformWorkingDir(cwd, []string{"-chdir=envs/prod", "plan"}); dir != filepath.Join(cwd, "envs", "prod") {
+		t.Errorf("Expected relative -chdir, got %s (unexpected)", dir)
+	}
+
+	if dir := pkg.TerraformWorkingDir(cwd, []string{"-chdir=" + abs, "plan"}); dir != abs {
+		t.Errorf("Expected absolute -chdir, got %s (unexpected)", dir)
+	}
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetSHA256Sums : download a release SHA256SUMS file, verify its signature
+// and return the checksums indexed by file name
+func GetSHA256Sums(shasumsURL string) (map[string]string, error) {
+	return getSHA256Sums(&HTTPSource{}, shasumsURL, shasumsURL+Terraform().SignatureExt)
+}
+
+// getSHA256Sums : read a release SHA256SUMS file and its signature from src, see GetSHA256Sums
+func getSHA256Sums(src Source, shasumsURL string, signatureURL string) (map[string]string, error) {
+	body, err := readArtifact(src, shasumsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := readArtifact(src, signatureURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifySignature(productOf(src), shasumsURL, body, signature); err != nil {
+		return nil, err
+	}
+
+	return ParseSHA256Sums(body)
+}
+
+// ParseSHA256Sums : parse the content of a SHA256SUMS file, one "<hex sum>  <file name>" per line
+func ParseSHA256Sums(content []byte) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: malformed SHA256SUMS line: %q", ErrChecksum, line)
+		}
+
+		// sha256sum marks binary mode files with a leading '*'
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+
+	return sums, scanner.Err()
+}
+
+// FileSHA256 : compute the hex encoded sha256 of a file
+func FileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChecksum : check that file matches its entry in the given checksums,
+// a file without entry is rejected
+func VerifyChecksum(file string, sums map[string]string) error {
+	name := filepath.Base(file)
+
+	expected, ok := sums[name]
+	if !ok {
+		return fmt.Errorf("%w: no checksum found for %s", ErrChecksum, name)
+	}
+
+	actual, err := FileSHA256(file)
+	if err != nil {
+		return opError(ErrFilesystem, "unable to read "+file, err)
+	}
+
+	if actual != expected {
+		return fmt.Errorf("%w: checksum mismatch for %s: expected %s, got %s", ErrChecksum, name, expected, actual)
+	}
+
+	log.Debugf("Checksum verified for %s: %s", name, actual)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package pkg_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestParseSHA256Sums : parse a SHA256SUMS file as published by hashicorp
+func TestParseSHA256Sums(t *testing.T) {
+	content := "0123abcd  terraform_1.0.0_linux_amd64.zip\n" +
+		"4567EF01 *terraform_1.0.0_darwin_amd64.zip\n\n"
+
+	sums, err := pkg.ParseSHA256Sums([]byte(content))
+	if err != nil {
+		t.Fatalf("Unable to parse checksums: %v (unexpected)", err)
+	}
+
+	if sums["terraform_1.0.0_linux_amd64.zip"] != "0123abcd" {
+		t.Errorf("Wrong checksum for linux zip: %v (unexpected)", sums)
+	}
+
+	if sums["terraform_1.0.0_darwin_amd64.zip"] != "4567ef01" {
+		t.Errorf("Wrong checksum for darwin zip: %v (unexpected)", sums)
+	}
+
+	if _, err := pkg.ParseSHA256Sums([]byte("garbage\n")); err == nil {
+		t.Error("Malformed checksums should not be parsed (unexpected)")
+	}
+}
+
+// TestVerifyChecksum : a file is only accepted when it matches its own entry
+func TestVerifyChecksum(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "terraform_1.0.0_linux_amd64.zip")
+	content := []byte("not really a zip")
+	if err := os.WriteFile(file, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(content)
+	good := hex.EncodeToString(sum[:])
+
+	if err := pkg.VerifyChecksum(file, map[string]string{filepath.Base(file): good}); err != nil {
+		t.Errorf("Valid checksum rejected: %v (unexpected)", err)
+	}
+
+	if err := pkg.VerifyChecksum(file, map[string]string{filepath.Base(file): "00" + good[2:]}); err == nil {
+		t.Error("Checksum mismatch accepted (unexpected)")
+	}
+
+	if err := pkg.VerifyChecksum(file, map[string]string{"terraform_1.0.0_darwin_amd64.zip": good}); err == nil {
+		t.Error("Missing checksum entry accepted (unexpected)")
+	}
+}
@@ -0,0 +1,143 @@
+// Package cli implements the management subcommands available when the binary is not invoked as terraform
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// ErrUsage : the command line is invalid, usage has already been printed
+var ErrUsage = errors.New("invalid usage")
+
+// Options : what the subcommands need from main
+type Options struct {
+	Version string
+	Source  pkg.Source
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// command : a management subcommand
+type command struct {
+	usage string
+	help  string
+	run   func(opts Options, args []string) error
+}
+
+// commands : the management subcommands by name
+func commands() map[string]command {
+	return map[string]command{
+		"list":      {"list [-remote] [-all] [-json]", "list the installed versions, or the versions of the mirror", runList},
+		"install":   {"install [-json] <version|constraint>", "install a version, or the version a constraint resolves to", runInstall},
+		"uninstall": {"uninstall [-json] <version>", "remove an installed version", runUninstall},
+		"which":     {"which [-json] [dir]", "show the terraform binary used in dir, the current directory by default", runWhich},
+		"version":   {"version [-json]", "show the version of simple-tfswitch", runVersion},
+		"locks":     {"locks [-json]", "show the processes holding the lock of a version", runLocks},
+		"config":    {"config [-json]", "show the effective configuration and where each setting comes from", runConfig},
+		"prefetch": {
+			"prefetch [-jobs N] [-version v]... [-json] [dir]...",
+			"install every version required under the directories, the current directory by default, and the versions given", runPrefetch,
+		},
+		"lock": {
+			"lock [-platform os_arch]... [-upgrade] [-json] [dir]",
+			"lock the version dir resolves to, with the hashes of its builds, in its closest lock file or a new one in dir", runLock,
+		},
+		"prune": {
+			"prune [-keep N] [-keep-days D] [-keep-repo dir]... [-max-size S] [-dry-run] [-json]",
+			"remove the installed versions not kept by the policy, see SIMPLE_TFSWITCH_PRUNE_* for the defaults", runPrune,
+		},
+	}
+}
+
+// IsPassThrough : the binary is invoked as terraform or tofu, through a symlink, and only forwards its arguments
+func IsPassThrough(argv0 string) bool {
+	return pkg.ProductInvokedAs(argv0) != nil
+}
+
+// Run : run the management subcommand given in args, an invalid command line is an ErrUsage
+func Run(args []string, opts Options) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(opts.Stderr)
+
+		return ErrUsage
+	}
+
+	cmd, ok := commands()[args[0]]
+	if !ok {
+		fmt.Fprintf(opts.Stderr, "unknown command %q\n\n", args[0])
+		usage(opts.Stderr)
+
+		return ErrUsage
+	}
+
+	return cmd.run(opts, args[1:])
+}
+
+// usage : print the available subcommands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: simple-tfswitch <command> [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Invoked as terraform, through a symlink, all arguments are forwarded to the terraform version required by the current directory.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+
+	cmds := commands()
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n      %s\n", cmds[name].usage, cmds[name].help)
+	}
+}
+
+// newFlagSet : a flag set for a subcommand, with the common -json flag
+func newFlagSet(opts Options, name string) (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(opts.Stderr)
+	jsonOutput := fs.Bool("json", false, "machine readable JSON output")
+	fs.Usage = func() {
+		fmt.Fprintf(opts.Stderr, "Usage: simple-tfswitch %s\n", commands()[name].usage)
+		fs.PrintDefaults()
+	}
+
+	return fs, jsonOutput
+}
+
+// parseArgs : parse the flags of a subcommand and check its number of positional arguments, maxArgs -1 for no limit
+func parseArgs(fs *flag.FlagSet, args []string, minArgs int, maxArgs int) error {
+	// the flag package already printed the error and the usage
+	if err := fs.Parse(args); err != nil {
+		return ErrUsage
+	}
+
+	if fs.NArg() < minArgs || (maxArgs >= 0 && fs.NArg() > maxArgs) {
+		fs.Usage()
+
+		return ErrUsage
+	}
+
+	return nil
+}
+
+// output : print v as JSON, or its text form
+func output(opts Options, jsonOutput bool, v interface{}, text string) error {
+	if !jsonOutput {
+		_, err := fmt.Fprintln(opts.Stdout, text)
+
+		return err
+	}
+
+	enc := json.NewEncoder(opts.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
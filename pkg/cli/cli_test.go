@@ -0,0 +1,174 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+	"github.com/terraform-tools/simple-tfswitch/pkg/cli"
+)
+
+// run : run a subcommand, return its output and error
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	stdout := &bytes.Buffer{}
+	err := cli.Run(args, cli.Options{
+		Version: "1.0.0-test",
+		Source:  &pkg.HTTPSource{URL: "http://127.0.0.1:1/terraform/"},
+		Stdout:  stdout,
+		Stderr:  &bytes.Buffer{},
+	})
+
+	return stdout.String(), err
+}
+
+// installedPath : where a version is installed
+func installedPath(t *testing.T, version string) string {
+	t.Helper()
+
+	path, err := pkg.InstalledPath(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// fakeInstall : pretend versions are installed
+func fakeInstall(t *testing.T, versions ...string) {
+	t.Helper()
+
+	for _, v := range versions {
+		if err := os.WriteFile(installedPath(t, v), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestIsPassThrough : only the terraform and tofu names forward arguments
+func TestIsPassThrough(t *testing.T) {
+	for argv0, expected := range map[string]bool{
+		"/usr/local/bin/terraform":        true,
+		"terraform.exe":                   true,
+		"/usr/local/bin/simple-tfswitch":  false,
+		"./simple-tfswitch":               false,
+		"/usr/local/bin/terraform-docs":   false,
+		"/usr/local/bin/tofu":             true,
+		filepath.Join("bin", "terraform"): true,
+	} {
+		if cli.IsPassThrough(argv0) != expected {
+			t.Errorf("Expected pass through %v for %s (unexpected)", expected, argv0)
+		}
+	}
+}
+
+// TestRunUsage : unknown commands and wrong arguments are usage errors
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"plan"}, {"install"}, {"uninstall", "1.0.0", "1.1.0"}, {"list", "-unknown"}} {
+		if _, err := run(t, args...); !errors.Is(err, cli.ErrUsage) {
+			t.Errorf("Expected usage error for %v, got %v (unexpected)", args, err)
+		}
+	}
+}
+
+// TestRunVersion : the version is printed as text or JSON
+func TestRunVersion(t *testing.T) {
+	if out, _ := run(t, "version"); out != "simple-tfswitch 1.0.0-test\n" {
+		t.Errorf("Unexpected version output %q", out)
+	}
+
+	out, _ := run(t, "version", "-json")
+	v := map[string]string{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil || v["version"] != "1.0.0-test" {
+		t.Errorf("Unexpected JSON version output %q: %v", out, err)
+	}
+}
+
+// TestRunListUninstall : list installed versions and remove one
+func TestRunListUninstall(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	fakeInstall(t, "1.1.0", "1.10.0", "0.12.31")
+
+	if out, err := run(t, "list"); err != nil || out != "1.10.0\n1.1.0\n0.12.31\n" {
+		t.Errorf("Unexpected list output %q, error %v", out, err)
+	}
+
+	if _, err := run(t, "uninstall", "1.1.0"); err != nil {
+		t.Errorf("Unable to uninstall: %v (unexpected)", err)
+	}
+
+	if _, err := run(t, "uninstall", "1.1.0"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Uninstalled a version not installed, error %v (unexpected)", err)
+	}
+
+	out, _ := run(t, "list", "-json")
+	infos := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatalf("Invalid JSON list output %q: %v", out, err)
+	}
+
+	if len(infos) != 2 || infos[0]["version"] != "1.10.0" || infos[0]["installed"] != true || infos[0]["path"] == "" {
+		t.Errorf("Unexpected JSON list output %v", infos)
+	}
+}
+
+// TestRunWhich : show the binary used for a directory without installing it
+func TestRunWhich(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("TFSWITCH_VERSION", "1.2.3")
+
+	out, err := run(t, "which", t.TempDir())
+	if err != nil || !strings.HasSuffix(out, " (not installed yet)\n") {
+		t.Errorf("Unexpected which output %q, error %v", out, err)
+	}
+
+	fakeInstall(t, "1.2.3")
+
+	out, _ = run(t, "which", "-json", t.TempDir())
+	info := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("Invalid JSON which output %q: %v", out, err)
+	}
+
+	if info["version"] != "1.2.3" || info["installed"] != true || info["source"] != "TFSWITCH_VERSION" ||
+		info["path"] != installedPath(t, "1.2.3") {
+		t.Errorf("Unexpected JSON which output %v", info)
+	}
+}
+
+// TestRunConfig : the effective configuration shows where each setting comes from
+func TestRunConfig(t *testing.T) {
+	t.Setenv("SIMPLE_TFSWITCH_CONFIG", filepath.Join(t.TempDir(), "config.hcl"))
+	t.Setenv("SIMPLE_TFSWITCH_DEFAULT_VERSION", "latest")
+
+	out, err := run(t, "config")
+	if err != nil {
+		t.Fatalf("Unable to show the configuration: %v (unexpected)", err)
+	}
+	if !strings.Contains(out, `default_version  = "latest" (env SIMPLE_TFSWITCH_DEFAULT_VERSION)`) {
+		t.Errorf("Expected the default version from the environment, got %q (unexpected)", out)
+	}
+
+	out, err = run(t, "config", "-json")
+	settings := []pkg.Setting{}
+	if err != nil || json.Unmarshal([]byte(out), &settings) != nil || len(settings) == 0 {
+		t.Errorf("Expected the settings as JSON, got %q, %v (unexpected)", out, err)
+	}
+}
+
+// TestRunPrefetch : the versions given are installed once
+func TestRunPrefetch(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	fakeInstall(t, "1.2.3")
+
+	out, err := run(t, "prefetch", "-jobs", "2", "-version", "1.2.3", "-version", "1.2.3")
+	if expected := "1.2.3 " + installedPath(t, "1.2.3") + "\n"; err != nil || out != expected {
+		t.Errorf("Expected %q, got %q, %v (unexpected)", expected, out, err)
+	}
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// versionInfo : a terraform version and where it is installed
+type versionInfo struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+	Path      string `json:"path,omitempty"`
+}
+
+// whichInfo : the terraform version used in a directory and why
+type whichInfo struct {
+	versionInfo
+	Constraint string `json:"constraint"`
+	Source     string `json:"source"`
+}
+
+func runList(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "list")
+	remote := fs.Bool("remote", false, "list the versions available on the mirror")
+	all := fs.Bool("all", false, "include pre-releases in the versions of the mirror")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	local, err := pkg.GetLocalTFList()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	versions := local
+	if *remote {
+		if versions, err = pkg.GetTFList(opts.Source, *all); err != nil {
+			return err
+		}
+	}
+
+	installed := map[string]bool{}
+	for _, v := range local {
+		installed[v] = true
+	}
+
+	infos := make([]versionInfo, len(versions))
+	lines := make([]string, len(versions))
+	for i, v := range versions {
+		infos[i] = versionInfo{Version: v, Installed: installed[v]}
+		lines[i] = v
+		if installed[v] {
+			if infos[i].Path, err = pkg.InstalledPath(v); err != nil {
+				return err
+			}
+			if *remote {
+				lines[i] += " (installed)"
+			}
+		}
+	}
+
+	return output(opts, *jsonOutput, infos, strings.Join(lines, "\n"))
+}
+
+func runInstall(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "install")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	tfversion, err := pkg.ResolveVersion(fs.Arg(0), opts.Source)
+	if err != nil {
+		return err
+	}
+
+	path, err := pkg.Install(tfversion, opts.Source)
+	if err != nil {
+		return err
+	}
+
+	return output(opts, *jsonOutput, versionInfo{Version: tfversion, Installed: true, Path: path}, path)
+}
+
+func runUninstall(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "uninstall")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	path, err := pkg.Uninstall(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return output(opts, *jsonOutput, versionInfo{Version: fs.Arg(0), Installed: false, Path: path}, "removed "+path)
+}
+
+func runWhich(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "which")
+	if err := parseArgs(fs, args, 0, 1); err != nil {
+		return err
+	}
+
+	dir := fs.Arg(0)
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		dir = cwd
+	}
+
+	tfversion, req, err := pkg.ResolveTFProvidedModule(dir, opts.Source)
+	if err != nil {
+		return err
+	}
+
+	path, err := pkg.InstalledPath(tfversion)
+	if err != nil {
+		return err
+	}
+	info := whichInfo{
+		versionInfo: versionInfo{Version: tfversion, Installed: pkg.CheckFileExist(path), Path: path},
+		Constraint:  req.Constraint,
+		Source:      req.Source,
+	}
+
+	text := path
+	if !info.Installed {
+		text += " (not installed yet)"
+	}
+
+	return output(opts, *jsonOutput, info, text)
+}
+
+func runVersion(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "version")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	return output(opts, *jsonOutput, map[string]string{"version": opts.Version}, "simple-tfswitch "+opts.Version)
+}
+
+func runLocks(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "locks")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	holders, err := pkg.GetLockHolders()
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, len(holders))
+	for i, h := range holders {
+		lines[i] = h.Product + " " + h.Version + " locked by " + h.String()
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(holders) == 0 {
+		text = "no lock held"
+	}
+
+	return output(opts, *jsonOutput, holders, text)
+}
+
+func runConfig(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "config")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	settings, err := pkg.Config()
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, s := range settings {
+		if len(s.Name) > width {
+			width = len(s.Name)
+		}
+	}
+
+	lines := make([]string, len(settings))
+	for i, s := range settings {
+		lines[i] = fmt.Sprintf("%-*s = %q (%s)", width, s.Name, s.Value, s.Origin)
+	}
+
+	return output(opts, *jsonOutput, settings, strings.Join(lines, "\n"))
+}
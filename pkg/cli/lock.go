@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+func runLock(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "lock")
+	platforms := stringsFlag{}
+	fs.Var(&platforms, "platform", "record the hash of this os_arch platform, repeatable, see SIMPLE_TFSWITCH_LOCK_PLATFORMS for the default")
+	upgrade := fs.Bool("upgrade", false, "resolve the version again, even when the locked version still satisfies the requirement")
+	if err := parseArgs(fs, args, 0, 1); err != nil {
+		return err
+	}
+
+	dir := fs.Arg(0)
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		dir = cwd
+	}
+	if len(platforms) == 0 {
+		platforms = pkg.LockPlatforms()
+	}
+
+	lock, err := pkg.UpdateLockFile(dir, platforms, *upgrade, opts.Source)
+	if err != nil {
+		return err
+	}
+
+	locked := make([]string, 0, len(lock.Hashes))
+	for platform := range lock.Hashes {
+		locked = append(locked, platform)
+	}
+	sort.Strings(locked)
+
+	return output(opts, *jsonOutput, lock,
+		fmt.Sprintf("%s %s locked in %s for %s", lock.Product, lock.Version, lock.File, strings.Join(locked, ", ")))
+}
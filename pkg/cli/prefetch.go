@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+func runPrefetch(opts Options, args []string) error {
+	fs, jsonOutput := newFlagSet(opts, "prefetch")
+	jobs := fs.Int("jobs", pkg.DefaultPrefetchJobs, "install at most N versions at a time")
+	versions := stringsFlag{}
+	fs.Var(&versions, "version", "also install this version or the version this constraint resolves to, repeatable")
+	if err := parseArgs(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 && len(versions) == 0 {
+		roots = []string{"."}
+	}
+
+	results, err := pkg.Prefetch(roots, versions, *jobs, opts.Source)
+	if results == nil {
+		return err
+	}
+
+	lines := make([]string, len(results))
+	for i, r := range results {
+		switch {
+		case r.Error != "" && r.Version == "":
+			lines[i] = fmt.Sprintf("%s: %s", r.Constraint, r.Error)
+		case r.Error != "":
+			lines[i] = fmt.Sprintf("%s: %s", r.Version, r.Error)
+		case len(r.Dirs) == 0:
+			lines[i] = fmt.Sprintf("%s %s", r.Version, r.Path)
+		default:
+			lines[i] = fmt.Sprintf("%s %s (%d directories)", r.Version, r.Path, len(r.Dirs))
+		}
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(results) == 0 {
+		text = "no version required"
+	}
+
+	if errOutput := output(opts, *jsonOutput, results, text); errOutput != nil {
+		return errOutput
+	}
+
+	return err
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// stringsFlag : a repeatable string flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+// sizeFlag : a size flag accepting units, as in 500M
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return fmt.Sprint(int64(*s))
+}
+
+func (s *sizeFlag) Set(value string) error {
+	size, err := pkg.ParseSize(value)
+	*s = sizeFlag(size)
+
+	return err
+}
+
+func runPrune(opts Options, args []string) error {
+	policy, err := pkg.PrunePolicyFromConfig()
+	if err != nil {
+		return err
+	}
+
+	fs, jsonOutput := newFlagSet(opts, "prune")
+	keepDays := int(policy.KeepUsedWithin / (24 * time.Hour))
+	repos := stringsFlag(policy.KeepReferencedBy)
+	maxSize := sizeFlag(policy.MaxSize)
+	fs.IntVar(&policy.KeepNewest, "keep", policy.KeepNewest, "keep the N newest versions")
+	fs.IntVar(&keepDays, "keep-days", keepDays, "keep the versions used within the last D days")
+	fs.Var(&repos, "keep-repo", "keep the versions used by the configurations of this directory, repeatable")
+	fs.Var(&maxSize, "max-size", "then remove the least recently used versions until the total size fits, as in 2G")
+	fs.BoolVar(&policy.DryRun, "dry-run", false, "only show what would be removed")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	policy.KeepUsedWithin = time.Duration(keepDays) * 24 * time.Hour
+	policy.KeepReferencedBy = repos
+	policy.MaxSize = int64(maxSize)
+
+	removed, err := pkg.Prune(policy)
+	if err != nil {
+		return err
+	}
+
+	verb := "removed"
+	if policy.DryRun {
+		verb = "would remove"
+	}
+
+	lines := make([]string, len(removed))
+	for i, v := range removed {
+		lines[i] = fmt.Sprintf("%s %s %s (%s)", verb, v.Product, v.Version, v.Path)
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(removed) == 0 {
+		text = "nothing to remove"
+	}
+
+	return output(opts, *jsonOutput, removed, text)
+}
@@ -0,0 +1,484 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
+)
+
+const (
+	configEnv         = "SIMPLE_TFSWITCH_CONFIG"
+	configDir         = "simple-tfswitch"
+	userConfigFile    = "config.hcl"
+	projectConfigFile = ".simple-tfswitch.hcl"
+
+	// OriginDefault : the origin of a setting set nowhere
+	OriginDefault = "default"
+	originEnv     = "env "
+
+	settingProduct        = "product"
+	settingMirrors        = "mirrors"
+	settingTofuMirrors    = "tofu_mirrors"
+	settingInstallDir     = "install_dir"
+	settingCacheTTL       = "cache_ttl"
+	settingOffline        = "offline"
+	settingDefaultVersion = "default_version"
+	settingPreReleases    = "pre_releases"
+	settingStrategy       = "strategy"
+	settingLockTimeout    = "lock_timeout"
+	settingKeyring        = "keyring"
+	settingTofuKeyring    = "tofu_keyring"
+	settingHTTPRetries    = "http_retries"
+	settingHTTPRetryDelay = "http_retry_delay"
+	settingHTTPTimeout    = "http_timeout"
+	settingProgress       = "progress"
+	settingTimeout        = "timeout"
+	settingKillGrace      = "kill_grace"
+	settingExec           = "exec"
+	settingLogLevel       = "log_level"
+	settingLogFormat      = "log_format"
+	settingLogFile        = "log_file"
+	settingLockPlatforms  = "lock_platforms"
+	settingPruneKeep      = "prune_keep"
+	settingPruneKeepDays  = "prune_keep_days"
+	settingPruneRepos     = "prune_repos"
+	settingPruneMaxSize   = "prune_max_size"
+	settingAutoPrune      = "auto_prune"
+)
+
+// settingKind : how the value of a setting is read from the configuration files
+type settingKind int
+
+const (
+	kindValue     settingKind = iota // a string, number or bool
+	kindPath                         // a path, relative to the file setting it
+	kindList                         // a list of values
+	kindLocations                    // a list of urls or paths, the paths relative to the file setting them
+)
+
+// settingDef : a setting of the configuration files, with the environment variable overriding it
+// and its default, computed when used as it may depend on the environment
+type settingDef struct {
+	name string
+	env  string
+	def  func() string
+	kind settingKind
+	help string
+}
+
+// fixed : a default which never changes
+func fixed(value string) func() string {
+	return func() string { return value }
+}
+
+var (
+	settingsOnce   sync.Once             //nolint:gochecknoglobals // builds the settings below
+	settingsTable  []settingDef          //nolint:gochecknoglobals // every setting, built once
+	settingsByName map[string]settingDef //nolint:gochecknoglobals // settingsTable by name
+)
+
+// settingDefs : every setting, in the order they are shown
+func settingDefs() []settingDef {
+	settingsOnce.Do(func() {
+		settingsTable = newSettingDefs()
+		settingsByName = make(map[string]settingDef, len(settingsTable))
+		for _, def := range settingsTable {
+			settingsByName[def.name] = def
+		}
+	})
+
+	return settingsTable
+}
+
+// lookupSettingDef : the setting name, false when it is unknown
+func lookupSettingDef(name string) (settingDef, bool) {
+	settingDefs()
+	def, ok := settingsByName[name]
+
+	return def, ok
+}
+
+// newSettingDefs : the table of settingDefs
+func newSettingDefs() []settingDef {
+	return []settingDef{
+		{settingProduct, productEnv, fixed(Terraform().Name), kindValue, "terraform or tofu, when neither a version file nor .tofu files choose"},
+		{settingMirrors, mirrorsEnv, fixed(DefaultMirror), kindLocations, "mirrors to try in order"},
+		{settingTofuMirrors, "SIMPLE_TFSWITCH_TOFU_MIRRORS", fixed(OpenTofuMirror), kindLocations, "mirrors of OpenTofu to try in order"},
+		{settingInstallDir, "SIMPLE_TFSWITCH_INSTALL_DIR", defaultInstallLocation, kindPath, "where versions are installed and cached"},
+		{settingCacheTTL, cacheTTLEnv, fixed(defaultCacheTTL.String()), kindValue, "how long the versions of a mirror are cached"},
+		{settingOffline, offlineEnv, fixed("false"), kindValue, "never use the network"},
+		{settingDefaultVersion, defaultVersionEnv, fixed(DefaultVersionError), kindValue, "latest, cached, error or a version, when none is required"},
+		{settingPreReleases, "SIMPLE_TFSWITCH_PRE_RELEASES", fixed("true"), kindValue, "whether pre-releases may match a constraint"},
+		{settingStrategy, "SIMPLE_TFSWITCH_STRATEGY", fixed(StrategyNewest), kindValue, "newest, oldest or oldest-minor, the version chosen among those matching a constraint"},
+		{settingLockTimeout, lockTimeoutEnv, fixed(defaultLockTimeout.String()), kindValue, "how long to wait for another install of a version"},
+		{settingKeyring, keyringEnv, func() string { return defaultKeyringPath(keyringFile) }, kindPath, "extra keys trusted to sign terraform releases"},
+		{settingTofuKeyring, "SIMPLE_TFSWITCH_TOFU_KEYRING", func() string { return defaultKeyringPath(tofuKeyringFile) }, kindPath, "keys trusted to sign OpenTofu releases"},
+		{settingHTTPRetries, "SIMPLE_TFSWITCH_HTTP_RETRIES", fixed(strconv.Itoa(defaultRetryAttempts)), kindValue, "retries of a failed request"},
+		{settingHTTPRetryDelay, "SIMPLE_TFSWITCH_HTTP_RETRY_DELAY", fixed(defaultRetryDelay.String()), kindValue, "delay between retries"},
+		{settingHTTPTimeout, "SIMPLE_TFSWITCH_HTTP_TIMEOUT", fixed("0s"), kindValue, "timeout of a request, download included, 0 for none"},
+		{settingProgress, "SIMPLE_TFSWITCH_PROGRESS", fixed(ProgressAuto), kindValue, "auto, bar, log or off, how downloads report their progress on stderr"},
+		{settingTimeout, "SIMPLE_TFSWITCH_TIMEOUT", fixed("0s"), kindValue, "how long terraform may run before it is interrupted, 0 for no limit"},
+		{settingKillGrace, "SIMPLE_TFSWITCH_KILL_GRACE", fixed(defaultKillGrace.String()), kindValue, "how long an interrupted terraform may take to stop before it is killed"},
+		{settingExec, "SIMPLE_TFSWITCH_EXEC", fixed("false"), kindValue, "replace simple-tfswitch by terraform instead of running it as a child, unix only"},
+		{settingLogLevel, "SIMPLE_TFSWITCH_LOG_LEVEL", defaultLogLevel, kindValue, "error, warn, info, debug or trace"},
+		{settingLogFormat, "SIMPLE_TFSWITCH_LOG_FORMAT", fixed(logger.FormatText), kindValue, "text or json"},
+		{settingLogFile, "SIMPLE_TFSWITCH_LOG_FILE", fixed(""), kindPath, "file the logs are appended to instead of stderr"},
+		{settingLockPlatforms, "SIMPLE_TFSWITCH_LOCK_PLATFORMS", fixed(defaultLockPlatforms), kindList, "os_arch platforms whose hashes the lock command records"},
+		{settingPruneKeep, pruneKeepEnv, fixed("0"), kindValue, "how many of the newest versions of each product prune keeps, 0 for no such rule"},
+		{settingPruneKeepDays, pruneKeepDaysEnv, fixed("0"), kindValue, "prune keeps the versions used within these days, 0 for no such rule"},
+		{settingPruneRepos, pruneReposEnv, fixed(""), kindLocations, "directories whose configurations prune keeps the versions of"},
+		{settingPruneMaxSize, pruneMaxSizeEnv, fixed("0"), kindValue, "total size prune removes the least recently used versions down to, as in 2G, 0 for no limit"},
+		{settingAutoPrune, autoPruneEnv, fixed("false"), kindValue, "prune at most once a day after an install"},
+	}
+}
+
+// Setting : the effective value of a setting and where it comes from,
+// an environment variable, a configuration file or the default
+type Setting struct {
+	Name   string `json:"name"`
+	Env    string `json:"env"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+	Help   string `json:"help"`
+}
+
+// configFile : the settings of a configuration file
+type configFile struct {
+	path   string
+	values map[string]string
+}
+
+// loadedConfig : the configuration files read by LoadConfig
+type loadedConfig struct {
+	paths []string
+	files []*configFile
+	err   error
+}
+
+var (
+	loaded   = &loadedConfig{} //nolint:gochecknoglobals // read once per process by LoadConfig
+	loadedMu sync.Mutex        //nolint:gochecknoglobals // guards loaded
+)
+
+// projectSetting : whether a project file may set the setting name. A project file comes with the repository,
+// so it only chooses the version: where binaries come from, which keys are trusted and where files are written
+// are only set by the user file and the environment.
+func projectSetting(name string) bool {
+	switch name {
+	case settingDefaultVersion, settingPreReleases, settingStrategy, settingProduct:
+		return true
+	default:
+		return false
+	}
+}
+
+// Config : the effective configuration, each setting from the first of its environment variable,
+// the project file, the user file, and its default, as loaded by LoadConfig
+func Config() ([]Setting, error) {
+	config := loadedFiles()
+	defs := settingDefs()
+
+	settings := make([]Setting, len(defs))
+	for i, def := range defs {
+		settings[i] = resolveSetting(def, config.files)
+	}
+
+	return settings, config.err
+}
+
+// ConfigFiles : the configuration files by precedence, the project file when there is one, then the user file,
+// as loaded by LoadConfig
+func ConfigFiles() []string {
+	return loadedFiles().paths
+}
+
+// LoadConfig : read the configuration files once for the process, the project file being the nearest one
+// from dir up, dir being the directory terraform runs in, and check they only hold known settings.
+// Until it is called, the settings come from the environment and the defaults only.
+func LoadConfig(dir string) error {
+	config := &loadedConfig{paths: []string{}}
+	project := projectConfigPath(dir)
+	if project != "" {
+		config.paths = append(config.paths, project)
+	}
+	if path, err := userConfigPath(); err == nil {
+		config.paths = append(config.paths, path)
+	}
+	config.files, config.err = readConfigFiles(config.paths, project)
+
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loaded = config
+
+	return config.err
+}
+
+// loadedFiles : the configuration files read by LoadConfig
+func loadedFiles() *loadedConfig {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+
+	return loaded
+}
+
+// resolveSetting : the effective value of a setting
+func resolveSetting(def settingDef, files []*configFile) Setting {
+	s := Setting{Name: def.name, Env: def.env, Value: def.def(), Origin: OriginDefault, Help: def.help}
+
+	if value := strings.TrimSpace(os.Getenv(def.env)); value != "" {
+		s.Value, s.Origin = value, originEnv+def.env
+
+		return s
+	}
+
+	for _, f := range files {
+		if value, ok := f.values[def.name]; ok {
+			s.Value, s.Origin = value, f.path
+
+			return s
+		}
+	}
+
+	return s
+}
+
+// configSetting : the effective value of the setting name
+func configSetting(name string) Setting {
+	if def, ok := lookupSettingDef(name); ok {
+		return resolveSetting(def, loadedFiles().files)
+	}
+
+	return Setting{Name: name, Origin: OriginDefault}
+}
+
+// configString : the effective value of the setting name
+func configString(name string) string {
+	return configSetting(name).Value
+}
+
+// configBool : the effective value of the setting name as a bool,
+// anything but an empty value, 0, false, no or off is true
+func configBool(name string) bool {
+	return parseBool(configString(name))
+}
+
+// configList : the effective value of the setting name as a list, separated by commas or spaces
+func configList(name string) []string {
+	return strings.FieldsFunc(configString(name), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// PreReleases : whether pre-releases may match a version constraint, set by the pre_releases setting
+// or SIMPLE_TFSWITCH_PRE_RELEASES, true by default
+func PreReleases() bool {
+	return configBool(settingPreReleases)
+}
+
+// configDuration : the effective value of the setting name as a duration (1h, 30s, ...),
+// def when it is invalid
+func configDuration(name string, def time.Duration) time.Duration {
+	s := configSetting(name)
+	value, err := time.ParseDuration(s.Value)
+	if err != nil {
+		log.Warnf("Invalid %s %q from %s, using %v: %v", s.Name, s.Value, s.Origin, def, err)
+
+		return def
+	}
+
+	return value
+}
+
+// configInt : the effective value of the setting name as a number, def when it is invalid
+func configInt(name string, def int) int {
+	s := configSetting(name)
+	value, err := strconv.Atoi(s.Value)
+	if err != nil {
+		log.Warnf("Invalid %s %q from %s, using %v: %v", s.Name, s.Value, s.Origin, def, err)
+
+		return def
+	}
+
+	return value
+}
+
+// parseBool : a value is true unless it is empty, 0, false, no or off
+func parseBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no", "off":
+		return false
+	default:
+		return true
+	}
+}
+
+// readConfigFiles : the existing configuration files among paths, by precedence, project being the project file,
+// the ones read before an invalid one along with its error
+func readConfigFiles(paths []string, project string) ([]*configFile, error) {
+	files := []*configFile{}
+	for _, path := range paths {
+		f, err := readConfigFile(path, path == project)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// readConfigFile : the settings of the HCL file at path, such as
+//
+//	mirrors         = ["https://mirror.example.com/terraform", "https://releases.hashicorp.com/terraform"]
+//	default_version = "latest"
+//	pre_releases    = false
+//
+// A project file may only hold the settings choosing the version, see projectSetting.
+func readConfigFile(path string, project bool) (*configFile, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err != nil {
+		return nil, opError(ErrConfig, "unable to read "+path, err)
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrConfig, diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrConfig, diags.Error())
+	}
+
+	f := &configFile{path: path, values: map[string]string{}}
+	for name, attr := range attrs {
+		def, ok := lookupSettingDef(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: unknown setting %q", ErrConfig, attr.Range, name)
+		}
+		if project && !projectSetting(name) {
+			return nil, fmt.Errorf("%w: %s: %s can only be set in the user configuration file or %s, not in a project file",
+				ErrConfig, attr.Range, name, def.env)
+		}
+
+		value, diags := decodeSetting(def, attr, filepath.Dir(path))
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%w: %s", ErrConfig, diags.Error())
+		}
+		f.values[name] = value
+	}
+
+	return f, nil
+}
+
+// decodeSetting : the value of a setting in a file of dir, as it would be given in its environment variable
+func decodeSetting(def settingDef, attr *hcl.Attribute, dir string) (string, hcl.Diagnostics) {
+	switch def.kind {
+	case kindList, kindLocations:
+		// a list, or a single value
+		values := []string{}
+		if diags := gohcl.DecodeExpression(attr.Expr, nil, &values); diags.HasErrors() {
+			var value string
+			if diags := gohcl.DecodeExpression(attr.Expr, nil, &value); diags.HasErrors() {
+				return "", diags
+			}
+			values = []string{value}
+		}
+		for i, value := range values {
+			if def.kind == kindLocations && !strings.Contains(value, "://") {
+				values[i] = relativeTo(dir, value)
+			}
+		}
+
+		return strings.Join(values, ","), nil
+	default:
+		var value string
+		if diags := gohcl.DecodeExpression(attr.Expr, nil, &value); diags.HasErrors() {
+			return "", diags
+		}
+		if def.kind == kindPath && value != "" {
+			value = relativeTo(dir, value)
+		}
+
+		return value, nil
+	}
+}
+
+// relativeTo : path, relative to dir when not absolute
+func relativeTo(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// userConfigPath : the user configuration file, <user config dir>/simple-tfswitch/config.hcl
+// unless set by SIMPLE_TFSWITCH_CONFIG
+func userConfigPath() (string, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, configDir, userConfigFile), nil
+}
+
+// projectConfigPath : the nearest .simple-tfswitch.hcl from dir up to the repository root, "" when there is none
+func projectConfigPath(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, projectConfigFile)
+		if CheckFileExist(path) {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || isRepositoryRoot(dir) {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// defaultInstallLocation : ~/.terraform.versions, or $SNAP_USER_COMMON/.terraform.versions for snapcraft users
+func defaultInstallLocation() string {
+	/* For snapcraft users, SNAP_USER_COMMON environment variable is set by default.
+	 * tfswitch does not have permission to save to $HOME/.terraform.versions for snapcraft users
+	 * tfswitch will save binaries into $SNAP_USER_COMMON/.terraform.versions */
+	if userCommon := os.Getenv("SNAP_USER_COMMON"); userCommon != "" {
+		return filepath.Join(userCommon, installPath)
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(usr.HomeDir, installPath)
+}
@@ -0,0 +1,197 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// chdir : change the working directory for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// writeConfig : write a configuration file
+func writeConfig(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadConfig : load the user file and the project file found from dir for the duration of the test
+func loadConfig(t *testing.T, userFile string, dir string) error {
+	t.Helper()
+
+	// then back to no configuration file for the other tests
+	t.Setenv("SIMPLE_TFSWITCH_CONFIG", filepath.Join(t.TempDir(), "none.hcl"))
+	t.Cleanup(func() {
+		_ = pkg.LoadConfig(os.TempDir())
+	})
+
+	t.Setenv("SIMPLE_TFSWITCH_CONFIG", userFile)
+
+	return pkg.LoadConfig(dir)
+}
+
+// TestConfig : each setting comes from its environment variable, the project file, the user file or its default
+func TestConfig(t *testing.T) {
+	userDir := t.TempDir()
+	userFile := filepath.Join(userDir, "config.hcl")
+	writeConfig(t, userFile, `
+mirrors         = ["https://mirror.example.com/terraform", "zips"]
+default_version = "latest"
+http_retries    = 5
+`)
+
+	project := t.TempDir()
+	projectFile := filepath.Join(project, ".simple-tfswitch.hcl")
+	writeConfig(t, projectFile, `
+default_version = "cached"
+pre_releases    = false
+`)
+	module := filepath.Join(project, "modules", "network")
+	if err := os.MkdirAll(module, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// the project file is found from the directory terraform runs in, not the working directory
+	chdir(t, t.TempDir())
+
+	t.Setenv("SIMPLE_TFSWITCH_CACHE_TTL", "5m")
+
+	if err := loadConfig(t, userFile, module); err != nil {
+		t.Fatalf("Unable to load the configuration: %v (unexpected)", err)
+	}
+	settings, err := pkg.Config()
+	if err != nil {
+		t.Fatalf("Unable to read the configuration: %v (unexpected)", err)
+	}
+
+	origins := map[string]string{}
+	for _, s := range settings {
+		origins[s.Name] = s.Origin
+	}
+	files := pkg.ConfigFiles()
+	if len(files) != 2 || files[0] != projectFile || files[1] != userFile {
+		t.Fatalf("Expected the project and user files, got %v (unexpected)", files)
+	}
+
+	for name, expected := range map[string]string{
+		"mirrors":         userFile,
+		"default_version": projectFile,
+		"pre_releases":    projectFile,
+		"http_retries":    userFile,
+		"cache_ttl":       "env SIMPLE_TFSWITCH_CACHE_TTL",
+		"lock_timeout":    pkg.OriginDefault,
+	} {
+		if origins[name] != expected {
+			t.Errorf("Expected %s from %s, got %s (unexpected)", name, expected, origins[name])
+		}
+	}
+
+	if expected := []string{"https://mirror.example.com/terraform", filepath.Join(userDir, "zips")}; !reflect.DeepEqual(pkg.Mirrors(), expected) {
+		t.Errorf("Expected mirrors %v, got %v (unexpected)", expected, pkg.Mirrors())
+	}
+	if policy := pkg.DefaultVersionPolicy(); policy != pkg.DefaultVersionCached {
+		t.Errorf("Expected the project default version policy, got %s (unexpected)", policy)
+	}
+	if pkg.PreReleases() {
+		t.Errorf("Expected pre-releases to be disabled by the project (unexpected)")
+	}
+	if ttl := pkg.CacheTTL(); ttl != 5*time.Minute {
+		t.Errorf("Expected the cache TTL of the environment, got %v (unexpected)", ttl)
+	}
+}
+
+// TestConfigInvalid : unknown settings and syntax errors are configuration errors
+func TestConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(t.TempDir(), "config.hcl")
+
+	if err := loadConfig(t, userFile, dir); err != nil {
+		t.Errorf("Expected a missing file to be no error, got %v (unexpected)", err)
+	}
+
+	for _, content := range []string{`mirror = "https://mirror.example.com/terraform"`, `default_version = `} {
+		writeConfig(t, userFile, content)
+		if err := pkg.LoadConfig(dir); !errors.Is(err, pkg.ErrConfig) {
+			t.Errorf("Expected a configuration error for %q, got %v (unexpected)", content, err)
+		}
+	}
+}
+
+// TestConfigProjectRestricted : a project file only chooses the version, it cannot change where binaries
+// come from, which keys are trusted or where files are written
+func TestConfigProjectRestricted(t *testing.T) {
+	project := t.TempDir()
+	userFile := filepath.Join(t.TempDir(), "config.hcl")
+	writeConfig(t, userFile, `mirrors = ["https://mirror.example.com/terraform"]`)
+
+	for _, content := range []string{
+		`mirrors = ["https://evil.example.com/terraform"]`,
+		`tofu_mirrors = ["https://evil.example.com/tofu"]`,
+		`keyring = "evil.asc"`,
+		`install_dir = "/tmp/evil"`,
+		`log_file = "/tmp/evil.log"`,
+		`prune_max_size = "1"`,
+	} {
+		writeConfig(t, filepath.Join(project, ".simple-tfswitch.hcl"), content)
+		if err := loadConfig(t, userFile, project); !errors.Is(err, pkg.ErrConfig) {
+			t.Errorf("Expected %q to be refused in a project file, got %v (unexpected)", content, err)
+		}
+	}
+
+	writeConfig(t, filepath.Join(project, ".simple-tfswitch.hcl"), `
+product         = "tofu"
+default_version = "latest"
+pre_releases    = false
+strategy        = "oldest"
+`)
+	if err := loadConfig(t, userFile, project); err != nil {
+		t.Errorf("Expected the version settings to be accepted in a project file, got %v (unexpected)", err)
+	}
+}
+
+// TestConfigProjectRepositoryRoot : the project file is looked for up to the repository root, not above it
+func TestConfigProjectRepositoryRoot(t *testing.T) {
+	parent := t.TempDir()
+	writeConfig(t, filepath.Join(parent, ".simple-tfswitch.hcl"), `default_version = "latest"`)
+	repo := filepath.Join(parent, "repo")
+	module := filepath.Join(repo, "modules", "network")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(module, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	userFile := filepath.Join(t.TempDir(), "config.hcl")
+
+	if err := loadConfig(t, userFile, module); err != nil {
+		t.Fatalf("Unable to load the configuration: %v (unexpected)", err)
+	}
+	if files := pkg.ConfigFiles(); len(files) != 1 || files[0] != userFile {
+		t.Errorf("Expected only the user file, got %v (unexpected)", files)
+	}
+}
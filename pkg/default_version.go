@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultVersionEnv = "SIMPLE_TFSWITCH_DEFAULT_VERSION"
+
+	// DefaultVersionLatest : install the latest stable version
+	DefaultVersionLatest = "latest"
+	// DefaultVersionCached : use the latest version already installed
+	DefaultVersionCached = "cached"
+	// DefaultVersionError : fail, this is the default policy
+	DefaultVersionError = "error"
+
+	anyStableVersion = ">= 0.0.0"
+)
+
+// DefaultVersionPolicy : what to do when a directory does not require any version,
+// one of latest, cached, error or an exact version to pin, set by the default_version setting or SIMPLE_TFSWITCH_DEFAULT_VERSION
+func DefaultVersionPolicy() string {
+	policy := strings.TrimSpace(configString(settingDefaultVersion))
+	if policy == "" {
+		return DefaultVersionError
+	}
+
+	return policy
+}
+
+// resolveDefaultVersion : the version chosen by the default version policy for dir
+func resolveDefaultVersion(dir string, src Source) (string, error) {
+	policy := DefaultVersionPolicy()
+	log.Debugf("No version required in %s, applying default version policy %q", dir, policy)
+
+	switch policy {
+	case DefaultVersionLatest:
+		return resolveConstraint(anyStableVersion, StrategyNewest, src)
+	case DefaultVersionCached:
+		versions, err := GetLocalTFList()
+		if err != nil {
+			return "", err
+		}
+		if len(versions) == 0 {
+			return "", fmt.Errorf("%w: no terraform version required in %s and none installed yet (%s=%s)",
+				ErrNoMatchingVersion, dir, defaultVersionEnv, policy)
+		}
+
+		return versions[0], nil
+	case DefaultVersionError:
+		return "", fmt.Errorf("%w: no terraform version required in %s: set required_version, "+
+			"add a .terraform-version file, or set %s or %s (latest, cached or a version)",
+			ErrNoMatchingVersion, dir, versionEnv, defaultVersionEnv)
+	default:
+		if !ValidVersionFormat(policy) {
+			return "", fmt.Errorf("%w: invalid %s %q: expecting latest, cached, error or a version", ErrInvalidVersion, defaultVersionEnv, policy)
+		}
+
+		return policy, nil
+	}
+}
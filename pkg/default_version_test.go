@@ -0,0 +1,44 @@
+package pkg_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestDefaultVersionPolicy : a directory without required version follows the default version policy
+func TestDefaultVersionPolicy(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("TFSWITCH_VERSION", "")
+	srv := newReleaseServer(t, "1.1.0", "1.2.3", "1.3.0-rc1")
+
+	dir := t.TempDir()
+	createDirIfNotExist(filepath.Join(dir, ".git"))
+
+	for _, policy := range []string{"", "error", "cached", "not-a-version"} {
+		t.Setenv("SIMPLE_TFSWITCH_DEFAULT_VERSION", policy)
+		if _, err := pkg.InstallTFProvidedModule(dir, srv.source()); err == nil {
+			t.Errorf("Policy %q should fail without installed versions (unexpected)", policy)
+		}
+	}
+
+	expectInstalled := func(policy string, version string) {
+		t.Helper()
+		t.Setenv("SIMPLE_TFSWITCH_DEFAULT_VERSION", policy)
+
+		installed, err := pkg.InstallTFProvidedModule(dir, srv.source())
+		if err != nil {
+			t.Fatalf("Policy %q failed: %v (unexpected)", policy, err)
+		}
+
+		if filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_"+version) {
+			t.Errorf("Policy %q installed %s instead of %s (unexpected)", policy, installed, version)
+		}
+	}
+
+	expectInstalled("1.1.0", "1.1.0")
+	expectInstalled("cached", "1.1.0")
+	expectInstalled("latest", "1.2.3")
+	expectInstalled("cached", "1.2.3")
+}
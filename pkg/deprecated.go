@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GetTFListFromURL : Get the list of available terraform versions of a mirror url, newest first
+//
+// Deprecated: use GetTFList with a Source from NewSource.
+func GetTFListFromURL(mirrorURL string, preRelease bool) ([]string, error) {
+	src, err := NewSource(mirrorURL, Terraform())
+	if err != nil {
+		return nil, err
+	}
+
+	return GetTFList(src, preRelease)
+}
+
+// GetTFURLBody : Get the lines of the index page of a mirror url
+//
+// Deprecated: use GetTFList, or GetCatalog, with a Source from NewSource.
+func GetTFURLBody(mirrorURL string) ([]string, error) {
+	resp, err := HTTPClient().Get(withSlash(mirrorURL))
+	if err != nil {
+		return nil, opError(ErrNetwork, "get "+mirrorURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: retrieving contents from url %s: %s", ErrNetwork, mirrorURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, opError(ErrNetwork, "read "+mirrorURL, err)
+	}
+
+	return strings.Split(string(body), "\n"), nil
+}
+
+// InstallFromURL : Install the provided terraform version from a mirror url
+//
+// Deprecated: use Install with a Source from NewSource.
+func InstallFromURL(tfversion string, mirrorURL string) (string, error) {
+	src, err := NewSource(mirrorURL, Terraform())
+	if err != nil {
+		return "", err
+	}
+
+	return Install(tfversion, src)
+}
+
+// InstallTFProvidedModuleFromURL : Install the terraform version required by dir from a mirror url
+//
+// Deprecated: use InstallTFProvidedModule with a Source from NewSource.
+func InstallTFProvidedModuleFromURL(dir string, mirrorURL string) (string, error) {
+	src, err := NewSource(mirrorURL, ProductFor(dir))
+	if err != nil {
+		return "", err
+	}
+
+	return InstallTFProvidedModule(dir, src)
+}
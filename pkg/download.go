@@ -1,52 +1,166 @@
 package pkg
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// DownloadFromURL : Downloads the binary from the source url
+const (
+	partialDir       = ".partial"
+	partialValidator = ".validator"
+	stalePartial     = 7 * 24 * time.Hour
+)
+
+// errInterrupted : the artifact stopped coming before its end, the download can be resumed
+var errInterrupted = errors.New("download interrupted")
+
+// DownloadFromURL : Downloads the binary from the source url into installLocation,
+// an interrupted download being kept under its .partial directory
 func DownloadFromURL(installLocation string, url string) (string, error) {
+	return downloadArtifact(&HTTPSource{}, installLocation, filepath.Join(installLocation, partialDir), url)
+}
+
+// downloadArtifact : copy an artifact of src to the directory dir, return the written file.
+// When src can resume downloads, the artifact is kept in the directory partials until complete, on the same
+// filesystem as dir to be moved there, an interrupted download is resumed where it stopped, even by a later run,
+// as long as the artifact did not change.
+func downloadArtifact(src Source, dir string, partials string, url string) (string, error) {
 	tokens := strings.Split(url, "/")
 	fileName := tokens[len(tokens)-1]
-	log.Debugf("Downloading to: %s", installLocation)
+	zipFile := filepath.Join(dir, fileName)
+	log.Debugf("Downloading to: %s", dir)
+
+	resumable, ok := src.(resumableSource)
+	if !ok {
+		if err := copyArtifact(src, url, zipFile); err != nil {
+			return "", err
+		}
 
-	response, err := HTTPClient().Get(url)
+		return zipFile, nil
+	}
+
+	partial, err := partialPath(partials, url)
 	if err != nil {
-		log.Errorln("Error while downloading", url, "-", err)
+		return "", err
+	}
 
+	retries := configInt(settingHTTPRetries, defaultRetryAttempts)
+	for attempt := 0; ; attempt++ {
+		if err = resumeArtifact(resumable, url, partial); !errors.Is(err, errInterrupted) || attempt >= retries {
+			break
+		}
+		log.Warnf("Download of %s interrupted, resuming: %v", fileName, err)
+	}
+	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != 200 {
-		// Sometimes hashicorp terraform file names are not consistent
-		// For example 0.12.0-alpha4 naming convention in the release repo is not consistent
-		return "", fmt.Errorf("unable to download from %s", url)
+	_ = os.Remove(partial + partialValidator)
+	if err := os.Rename(partial, zipFile); err != nil {
+		return "", opError(ErrFilesystem, "unable to move the download of "+url, err)
 	}
 
-	zipFile := filepath.Join(installLocation, fileName)
-	output, err := os.Create(zipFile)
+	return zipFile, nil
+}
+
+// copyArtifact : copy an artifact of src to the file path
+func copyArtifact(src Source, url string, path string) error {
+	r, err := src.Open(url)
 	if err != nil {
-		log.Errorln("Error while creating", zipFile, "-", err)
+		return err
+	}
+	defer r.Close()
 
-		return "", err
+	output, err := os.Create(path)
+	if err != nil {
+		return opError(ErrFilesystem, "unable to create "+path, err)
 	}
 	defer output.Close()
 
-	n, err := io.Copy(output, response.Body)
+	p := newProgress(filepath.Base(path), artifactSize(r), 0)
+	n, err := io.Copy(output, io.TeeReader(r, p))
+	p.finish()
 	if err != nil {
-		log.Errorln("Error while downloading", url, "-", err)
+		return opError(ErrNetwork, "unable to download from "+url, err)
+	}
+	log.Debugln(n, "bytes downloaded")
 
-		return "", err
+	return nil
+}
+
+// resumeArtifact : download the rest of an artifact of src to the file partial,
+// along with the validator of the artifact to resume it later, errInterrupted when it stopped before its end
+func resumeArtifact(src resumableSource, url string, partial string) error {
+	offset := int64(0)
+	validator := ""
+	if content, err := os.ReadFile(partial + partialValidator); err == nil {
+		validator = string(content)
 	}
+	if info, err := os.Stat(partial); err == nil && validator != "" {
+		offset = info.Size()
+	}
+
+	r, start, validator, err := src.OpenFrom(url, offset, validator)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if start > 0 {
+		log.Infof("Resuming download of %s at %s", url, FormatSize(start))
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	output, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return opError(ErrFilesystem, "unable to write "+partial, err)
+	}
+	defer output.Close()
 
+	// without a validator, what was downloaded so far can only be thrown away
+	if validator == "" {
+		_ = os.Remove(partial + partialValidator)
+	} else if err := os.WriteFile(partial+partialValidator, []byte(validator), 0o644); err != nil {
+		return opError(ErrFilesystem, "unable to write "+partial+partialValidator, err)
+	}
+
+	p := newProgress(filepath.Base(url), artifactSize(r), start)
+	n, err := io.Copy(output, io.TeeReader(r, p))
+	p.finish()
+	if err != nil {
+		return opError(ErrNetwork, "unable to download from "+url, opError(errInterrupted, FormatSize(start+n)+" downloaded", err))
+	}
 	log.Debugln(n, "bytes downloaded")
 
-	return zipFile, nil
+	return nil
+}
+
+// partialPath : where the download of url is kept until complete, in the directory dir so that later runs resume it,
+// the partial downloads left behind for a week are removed
+func partialPath(dir string, url string) (string, error) {
+	if err := CreateDirIfNotExist(dir); err != nil {
+		return "", err
+	}
+
+	stale, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, path := range stale {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stalePartial {
+			log.Debugf("Removing stale partial download %s", path)
+			_ = os.Remove(path)
+		}
+	}
+
+	// the same file name may come from different mirrors
+	sum := sha256.Sum256([]byte(url))
+	tokens := strings.Split(url, "/")
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+"_"+tokens[len(tokens)-1]), nil
 }
@@ -1,6 +1,9 @@
 package pkg_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -209,3 +212,119 @@ func TestDownloadFromURL_Valid(t *testing.T) {
 		t.Logf("Valid URL from %v", url)
 	}
 }
+
+// partialDownloads : the partial downloads left in the install location
+func partialDownloads(t *testing.T) []string {
+	t.Helper()
+
+	partials, err := filepath.Glob(filepath.Join(os.Getenv("SNAP_USER_COMMON"), ".terraform.versions", ".partial", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return partials
+}
+
+// resumedFrom : the first byte of the ranges requested, "" when none was
+func resumedFrom(srv *releaseServer) string {
+	for _, r := range srv.rangesReceived() {
+		if r != "" {
+			return r
+		}
+	}
+
+	return ""
+}
+
+// TestInstallResumesDownload : an interrupted download is resumed where it stopped, in the same run or the next one
+func TestInstallResumesDownload(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3", "1.3.0")
+
+	zipPath := srv.cutNext("1.2.3")
+	if _, err := pkg.Install("1.2.3", srv.source()); err != nil {
+		t.Fatalf("Unable to install 1.2.3 after an interruption: %v (unexpected)", err)
+	}
+	if expected := fmt.Sprintf("bytes=%d-", len(srv.files[zipPath])/2); resumedFrom(srv) != expected {
+		t.Errorf("Expected the download to resume with %s, got ranges %q (unexpected)", expected, srv.rangesReceived())
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_HTTP_RETRIES", "0")
+	srv.cutNext("1.3.0")
+	if _, err := pkg.Install("1.3.0", srv.source()); !errors.Is(err, pkg.ErrNetwork) {
+		t.Fatalf("Expected the interrupted download to fail without retries, got %v (unexpected)", err)
+	}
+	if partials := partialDownloads(t); len(partials) != 2 {
+		t.Errorf("Expected the partial download and its validator to be kept, got %v (unexpected)", partials)
+	}
+
+	if _, err := pkg.Install("1.3.0", srv.source()); err != nil {
+		t.Fatalf("Unable to resume the download of 1.3.0: %v (unexpected)", err)
+	}
+	if partials := partialDownloads(t); len(partials) != 0 {
+		t.Errorf("Expected no partial download once complete, got %v (unexpected)", partials)
+	}
+}
+
+// TestDownloadFromURLKeepsPartialInTarget : an interrupted download is kept next to its target directory,
+// not in the install location which may be on another filesystem
+func TestDownloadFromURLKeepsPartialInTarget(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_HTTP_RETRIES", "0")
+	srv := newReleaseServer(t, "1.2.3")
+	target := t.TempDir()
+
+	zipPath := srv.cutNext("1.2.3")
+	url := srv.URL + "/terraform/" + zipPath
+	if _, err := pkg.DownloadFromURL(target, url); !errors.Is(err, pkg.ErrNetwork) {
+		t.Fatalf("Expected the interrupted download to fail without retries, got %v (unexpected)", err)
+	}
+	if partials, _ := filepath.Glob(filepath.Join(target, ".partial", "*")); len(partials) != 2 {
+		t.Errorf("Expected the partial download and its validator in the target directory, got %v (unexpected)", partials)
+	}
+	if partials := partialDownloads(t); len(partials) != 0 {
+		t.Errorf("Expected no partial download in the install location, got %v (unexpected)", partials)
+	}
+
+	downloaded, err := pkg.DownloadFromURL(target, url)
+	if err != nil || downloaded != filepath.Join(target, filepath.Base(zipPath)) {
+		t.Fatalf("Unable to resume the download: %v, %v (unexpected)", downloaded, err)
+	}
+}
+
+// TestInstallRestartsChangedDownload : a partial download of an artifact which changed since is thrown away
+func TestInstallRestartsChangedDownload(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_HTTP_RETRIES", "0")
+	srv := newReleaseServer(t, "1.2.3")
+
+	zipPath := srv.cutNext("1.2.3")
+	if _, err := pkg.Install("1.2.3", srv.source()); err == nil {
+		t.Fatal("Expected the interrupted download to fail without retries (unexpected)")
+	}
+
+	// the zip is published again, with another content
+	rebuilt := fakeTerraformZip(t, "1.2.3-rebuilt")
+	sum := sha256.Sum256(rebuilt)
+	srv.files[zipPath] = rebuilt
+	srv.setSHA256Sums(t, "1.2.3", []byte(hex.EncodeToString(sum[:])+"  "+filepath.Base(zipPath)+"\n"))
+
+	if _, err := pkg.Install("1.2.3", srv.source()); err != nil {
+		t.Fatalf("Unable to install the rebuilt 1.2.3: %v (unexpected)", err)
+	}
+}
+
+// TestInstallWithoutRanges : a server not supporting ranges gets the whole artifact again
+func TestInstallWithoutRanges(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+	srv.noRanges = true
+
+	srv.cutNext("1.2.3")
+	if _, err := pkg.Install("1.2.3", srv.source()); err != nil {
+		t.Fatalf("Unable to install 1.2.3 after an interruption: %v (unexpected)", err)
+	}
+	if r := resumedFrom(srv); r != "" {
+		t.Errorf("Expected no range request, got %s (unexpected)", r)
+	}
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of errors returned by pkg, to be checked with errors.Is.
+// ErrOffline and *SignatureError are more specific network and checksum errors.
+var (
+	// ErrInvalidVersion : a version, constraint or version policy cannot be parsed
+	ErrInvalidVersion = errors.New("invalid version")
+	// ErrNoMatchingVersion : no version satisfies the requirements
+	ErrNoMatchingVersion = errors.New("no matching version")
+	// ErrNetwork : a mirror could not be reached or answered an error
+	ErrNetwork = errors.New("network error")
+	// ErrChecksum : a download does not match its published checksums, or they are not trusted
+	ErrChecksum = errors.New("checksum verification failed")
+	// ErrLockTimeout : the lock of a version was held too long by another process, see *LockTimeoutError
+	ErrLockTimeout = errors.New("lock timeout")
+	// ErrFilesystem : the install location could not be read or written
+	ErrFilesystem = errors.New("filesystem error")
+	// ErrConfig : a configuration file cannot be read, or holds an unknown or invalid setting
+	ErrConfig = errors.New("invalid configuration")
+)
+
+// ErrOffline : the network is needed but offline mode is on, an ErrNetwork
+var ErrOffline = fmt.Errorf("%w: offline mode is on (%s)", ErrNetwork, offlineEnv)
+
+// OpError : an operation which failed with an error of the given kind
+type OpError struct {
+	Kind error
+	Op   string
+	Err  error
+}
+
+func (e *OpError) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Is : an OpError is an error of its kind, as well as its cause
+func (e *OpError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// opError : wrap err as an error of kind, nil stays nil
+func opError(kind error, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &OpError{Kind: kind, Op: op, Err: err}
+}
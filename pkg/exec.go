@@ -2,28 +2,147 @@ package pkg
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/signal"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	defaultKillGrace = time.Minute
+	// ExitTimeout : the exit code of a run stopped by the timeout setting, as timeout(1)
+	ExitTimeout = 124
+)
+
+// RunTFProvidedModule : install the version required for dir and run it with args, see RunTerraform.
+// A binary removed by a concurrent prune between its install and its start is installed again, once.
+func RunTFProvidedModule(dir string, src Source, args ...string) (int, error) {
+	tfBinaryPath, err := InstallTFProvidedModule(dir, src)
+	if err != nil {
+		return 0, err
+	}
+
+	code, err := runTerraform(tfBinaryPath, args)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Warnf("%s was removed before it started, installing it again", tfBinaryPath)
+		if tfBinaryPath, err = InstallTFProvidedModule(dir, src); err != nil {
+			return 0, err
+		}
+		code, err = runTerraform(tfBinaryPath, args)
+	}
+	if err != nil {
+		log.Errorf("Failed launching terraform binary %v", err)
+	}
+
+	return code, nil
+}
+
+// RunTerraform : run the binary at tfBinaryPath with args as a child process and return its exit code.
+// The signals asking it to stop are forwarded to it, once: the first Ctrl-C still lets terraform cancel gracefully.
+// It is interrupted when it runs longer than the timeout setting, and killed when still running
+// after the kill_grace setting. With the exec setting, on unix, the wrapper is replaced by the binary instead.
 func RunTerraform(tfBinaryPath string, args ...string) int {
+	code, err := runTerraform(tfBinaryPath, args)
+	if err != nil {
+		log.Errorf("Failed launching terraform binary %v", err)
+	}
+
+	return code
+}
+
+// runTerraform : see RunTerraform, the error telling the binary could not be started, nothing ran then
+func runTerraform(tfBinaryPath string, args []string) (int, error) {
+	timeout := configDuration(settingTimeout, 0)
+	if configBool(settingExec) {
+		if timeout > 0 {
+			log.Warnf("Ignoring %s: a timeout needs simple-tfswitch to supervise terraform, not to exec it", settingExec)
+		} else {
+			// nothing survives the exec
+			WaitRefreshes()
+			err := execBinary(tfBinaryPath, args)
+			if errors.Is(err, fs.ErrNotExist) {
+				return -1, err
+			}
+			log.Warnf("Unable to exec %s, running it as a child process: %v", tfBinaryPath, err)
+		}
+	}
+
 	cmd := exec.Command(tfBinaryPath, args...)
 	cmd.Env = os.Environ()
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	forward := isolate(cmd)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals()...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var deadline, kill <-chan time.Time
+	timedOut := false
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
 
-	if err := cmd.Run(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			return exitErr.ExitCode()
+	for {
+		select {
+		case err := <-done:
+			if timedOut {
+				return ExitTimeout, nil
+			}
+
+			return exitCode(err), nil
+		case sig := <-signals:
+			if !forward(sig) {
+				log.Debugf("Not forwarding %v, terraform received it as well", sig)
+
+				continue
+			}
+			log.Infof("Forwarding %v to terraform", sig)
+			if err := cmd.Process.Signal(sig); err != nil {
+				log.Debugf("Unable to forward %v to terraform: %v", sig, err)
+			}
+		case <-deadline:
+			grace := configDuration(settingKillGrace, defaultKillGrace)
+			log.Errorf("Terraform still running after %v, interrupting it, killing it in %v", timeout, grace)
+			if err := cmd.Process.Signal(os.Interrupt); err != nil {
+				log.Debugf("Unable to interrupt terraform: %v", err)
+			}
+			deadline, timedOut = nil, true
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			kill = timer.C
+		case <-kill:
+			log.Errorf("Terraform still running after the grace period, killing it")
+			_ = cmd.Process.Kill()
 		}
-		log.Errorf("Failed launching terraform binary %v", err)
+	}
+}
+
+// exitCode : the exit code of the child process from the error of its wait, 128 + the signal when killed by one
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitStatus(exitErr)
+	default:
+		log.Errorf("Failed waiting for terraform binary %v", err)
 
 		return -1
 	}
-
-	return 0
 }
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package pkg
+
+import "syscall"
+
+// sysProcAttr : the attributes of the terraform process, none beyond its process group on this platform
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{}
+}
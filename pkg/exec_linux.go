@@ -0,0 +1,8 @@
+package pkg
+
+import "syscall"
+
+// sysProcAttr : terraform is sent SIGTERM when simple-tfswitch dies without a chance to forward anything
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
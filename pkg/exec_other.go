@@ -0,0 +1,33 @@
+//go:build !unix
+
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// forwardedSignals : the signals asking terraform to stop
+func forwardedSignals() []os.Signal {
+	return []os.Signal{os.Interrupt}
+}
+
+// isolate : prepare cmd to run terraform and return whether a signal received by simple-tfswitch must be forwarded,
+// never as the console sends Ctrl-C to terraform as well, and an interrupt cannot be sent to a process here
+func isolate(*exec.Cmd) func(os.Signal) bool {
+	return func(os.Signal) bool {
+		return false
+	}
+}
+
+// execBinary : replacing the current process is not supported on this platform
+func execBinary(string, []string) error {
+	return fmt.Errorf("%w: exec is not supported on %s", ErrConfig, runtime.GOOS)
+}
+
+// exitStatus : the exit code of terraform
+func exitStatus(err *exec.ExitError) int {
+	return err.ExitCode()
+}
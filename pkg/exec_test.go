@@ -0,0 +1,103 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// newScript : an executable shell script standing for terraform, skipping the test where there is no sh
+func newScript(t *testing.T, script string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh on windows")
+	}
+	path := filepath.Join(t.TempDir(), "terraform")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// expectExit : run the script with args and check its exit code, and that it took less than within
+func expectExit(t *testing.T, script string, within time.Duration, expected int, args ...string) {
+	t.Helper()
+
+	start := time.Now()
+	if code := pkg.RunTerraform(script, args...); code != expected {
+		t.Errorf("Expected exit code %d, got %d (unexpected)", expected, code)
+	}
+	if elapsed := time.Since(start); elapsed > within {
+		t.Errorf("Expected to stop within %v, took %v (unexpected)", within, elapsed)
+	}
+}
+
+// TestRunTerraformExitCode : the exit code of terraform is returned, as 128 + the signal when killed by one
+func TestRunTerraformExitCode(t *testing.T) {
+	script := newScript(t, `[ "$1" = kill ] && kill -KILL $$; exit $1`)
+
+	expectExit(t, script, 10*time.Second, 0, "0")
+	expectExit(t, script, 10*time.Second, 7, "7")
+	expectExit(t, script, 10*time.Second, 128+9, "kill")
+}
+
+// TestRunTerraformTimeout : terraform still running after the timeout is interrupted,
+// and killed when it does not stop within the grace period
+func TestRunTerraformTimeout(t *testing.T) {
+	t.Setenv("SIMPLE_TFSWITCH_TIMEOUT", "200ms")
+	t.Setenv("SIMPLE_TFSWITCH_KILL_GRACE", "1h")
+	graceful := newScript(t, "trap 'exit 3' INT\nwhile :; do sleep 0.1; done")
+	expectExit(t, graceful, 10*time.Second, pkg.ExitTimeout)
+
+	t.Setenv("SIMPLE_TFSWITCH_KILL_GRACE", "200ms")
+	stubborn := newScript(t, "trap '' INT\nwhile :; do sleep 0.1; done")
+	expectExit(t, stubborn, 10*time.Second, pkg.ExitTimeout)
+
+	// the exec setting cannot apply with a timeout
+	t.Setenv("SIMPLE_TFSWITCH_EXEC", "true")
+	expectExit(t, graceful, 10*time.Second, pkg.ExitTimeout)
+}
+
+// TestRunTerraformForwardSignal : a SIGTERM received by simple-tfswitch is forwarded to terraform
+func TestRunTerraformForwardSignal(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	script := newScript(t, "trap 'exit 5' TERM\ntouch "+ready+"\nwhile :; do sleep 0.1; done")
+
+	go func() {
+		for !pkg.CheckFileExist(ready) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			_ = p.Signal(syscall.SIGTERM)
+		}
+	}()
+
+	expectExit(t, script, 10*time.Second, 5)
+}
+
+// TestRunTFProvidedModule : the version required by the module is installed and run
+func TestRunTFProvidedModule(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh on windows")
+	}
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_VERSION", "")
+	srv := newReleaseServer(t, "1.2.3")
+	_, module := newRepository(t)
+
+	code, err := pkg.RunTFProvidedModule(module, srv.source(), "version")
+	if err != nil || code != 0 {
+		t.Fatalf("Expected terraform to run, got %d: %v (unexpected)", code, err)
+	}
+
+	if installed, err := pkg.GetInstalledVersions(); err != nil || len(installed) != 1 || installed[0].Version != "1.2.3" {
+		t.Errorf("Expected 1.2.3 to be installed, got %v: %v (unexpected)", installed, err)
+	}
+}
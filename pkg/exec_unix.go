@@ -0,0 +1,63 @@
+//go:build unix
+
+package pkg
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const signalExitBase = 128
+
+// forwardedSignals : the signals asking terraform to stop
+func forwardedSignals() []os.Signal {
+	return []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+}
+
+// isolate : prepare cmd to run terraform and return whether a signal received by simple-tfswitch must be forwarded.
+// In the foreground of a terminal, terraform stays in the process group the terminal sends Ctrl-C to,
+// so it gets it once, from the terminal. Otherwise it gets a process group of its own,
+// and every signal is forwarded, as a supervisor sending them to simple-tfswitch only expects.
+func isolate(cmd *exec.Cmd) func(os.Signal) bool {
+	cmd.SysProcAttr = sysProcAttr()
+	if inForeground() {
+		return func(sig os.Signal) bool {
+			return sig != syscall.SIGINT
+		}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	return func(os.Signal) bool {
+		return true
+	}
+}
+
+// inForeground : whether simple-tfswitch is in the foreground process group of the terminal of its stdin
+func inForeground() bool {
+	pgrp, err := unix.IoctlGetInt(int(os.Stdin.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+
+	return pgrp == unix.Getpgrp()
+}
+
+// execBinary : replace simple-tfswitch by the binary at path, only returning on failure
+func execBinary(path string, args []string) error {
+	log.Debugf("Replacing simple-tfswitch by %s", path)
+
+	return syscall.Exec(path, append([]string{path}, args...), os.Environ())
+}
+
+// exitStatus : the exit code of terraform, 128 + the signal which killed it as shells do
+func exitStatus(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalExitBase + int(status.Signal())
+	}
+
+	return err.ExitCode()
+}
@@ -2,13 +2,18 @@ package pkg
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// maxUnzipSize : the largest file extracted from a zip, terraform binaries are below 100MB
+const maxUnzipSize = 1 << 30
+
 // RenameFile : rename file name
 func RenameFile(src string, dest string) {
 	if err := os.Rename(src, dest); err != nil {
@@ -16,17 +21,19 @@ func RenameFile(src string, dest string) {
 	}
 }
 
-// RemoveFiles : remove file
-func RemoveFiles(src string) {
+// RemoveFiles : remove the files matching the pattern src
+func RemoveFiles(src string) error {
 	files, err := filepath.Glob(src)
 	if err != nil {
-		panic(err)
+		return opError(ErrFilesystem, "remove "+src, err)
 	}
 	for _, f := range files {
 		if err := os.Remove(f); err != nil {
-			panic(err)
+			return opError(ErrFilesystem, "remove "+src, err)
 		}
 	}
+
+	return nil
 }
 
 // CheckFileExist : check if file exist in directory
@@ -55,16 +62,37 @@ func Unzip(src string, dest string) error {
 	return nil
 }
 
-// handle 1 zip file
-func handleZipFile(f *zip.File, dest string) error {
-	rc, err := f.Open()
+// UnzipFile will extract only the file named name at the root of the zip archive src
+// to the output directory dest, and return its path.
+func UnzipFile(src string, dest string, name string) (string, error) {
+	r, err := zip.OpenReader(src)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		if err := handleZipFile(f, dest); err != nil {
+			return "", err
+		}
+
+		return filepath.Join(dest, name), nil
 	}
-	defer rc.Close()
 
-	// Store filename/path for returning and using later on
+	return "", fmt.Errorf("%s not found in %s", name, src)
+}
+
+// handle 1 zip file
+func handleZipFile(f *zip.File, dest string) error {
+	// Store filename/path for returning and using later on,
+	// refusing entries escaping dest (zip slip)
 	fpath := filepath.Join(dest, f.Name)
+	if rel, err := filepath.Rel(dest, fpath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("illegal file path in zip: %s", f.Name)
+	}
 
 	if f.FileInfo().IsDir() {
 		// Make Folder
@@ -76,6 +104,17 @@ func handleZipFile(f *zip.File, dest string) error {
 		return nil
 	}
 
+	// Refuse what would fill the disk (zip bomb)
+	if f.UncompressedSize64 > maxUnzipSize {
+		return fmt.Errorf("%s is too large to be extracted: %d bytes", f.Name, f.UncompressedSize64)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
 	// Make File
 	if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
 		return err
@@ -86,7 +125,11 @@ func handleZipFile(f *zip.File, dest string) error {
 		return err
 	}
 
-	_, err = io.Copy(outFile, rc)
+	// The declared size may lie, never write more than the limit
+	n, err := io.Copy(outFile, io.LimitReader(rc, maxUnzipSize+1))
+	if err == nil && n > maxUnzipSize {
+		err = fmt.Errorf("%s is too large to be extracted", f.Name)
+	}
 
 	// Close the file without defer to close before next iteration of loop
 	outFile.Close()
@@ -95,15 +138,15 @@ func handleZipFile(f *zip.File, dest string) error {
 }
 
 // CreateDirIfNotExist : create directory if directory does not exist
-func CreateDirIfNotExist(dir string) {
+func CreateDirIfNotExist(dir string) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		log.Debugf("Creating directory for terraform binary at: %v", dir)
-		err = os.MkdirAll(dir, 0o755)
-		if err != nil {
-			log.Errorf("Unable to create directory for terraform binary at: %v", dir)
-			panic(err)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return opError(ErrFilesystem, "unable to create directory for terraform binary", err)
 		}
 	}
+
+	return nil
 }
 
 // CheckDirExist : check if directory exist
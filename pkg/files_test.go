@@ -1,6 +1,7 @@
 package pkg_test
 
 import (
+	"archive/zip"
 	"fmt"
 	"log"
 	"os"
@@ -86,7 +87,9 @@ func TestRemoveFiles(t *testing.T) {
 		t.Error("Missing file")
 	}
 
-	pkg.RemoveFiles(installFilePath)
+	if err := pkg.RemoveFiles(installFilePath); err != nil {
+		t.Errorf("Unable to remove %v: %v (unexpected)", installFilePath, err)
+	}
 
 	if exist := checkFileExist(installFilePath); exist {
 		t.Logf("Old file should not exist %v", installFilePath)
@@ -153,7 +156,9 @@ func TestCreateDirIfNotExist(t *testing.T) {
 		t.Error("Directory should not exist")
 	}
 
-	pkg.CreateDirIfNotExist(installLocation)
+	if err := pkg.CreateDirIfNotExist(installLocation); err != nil {
+		t.Errorf("Unable to create %v: %v (unexpected)", installLocation, err)
+	}
 	t.Logf("Creating directory %v", installLocation)
 
 	if _, err := os.Stat(installLocation); err == nil {
@@ -247,3 +252,70 @@ func TestConvertExecutableExt(t *testing.T) {
 		}
 	}
 }
+
+// writeZip : create a zip archive with the given entries
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUnzipFile : only the requested file is extracted
+func TestUnzipFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "release.zip")
+	dest := filepath.Join(dir, "dest")
+	writeZip(t, src, map[string]string{"terraform": "binary", "LICENSE.txt": "license"})
+
+	extracted, err := pkg.UnzipFile(src, dest, "terraform")
+	if err != nil {
+		t.Fatalf("Unable to extract: %v (unexpected)", err)
+	}
+
+	if extracted != filepath.Join(dest, "terraform") || !checkFileExist(extracted) {
+		t.Errorf("Extracted file %v does not exist (unexpected)", extracted)
+	}
+
+	if checkFileExist(filepath.Join(dest, "LICENSE.txt")) {
+		t.Error("Other files should not be extracted (unexpected)")
+	}
+
+	if _, err := pkg.UnzipFile(src, dest, "tofu"); err == nil {
+		t.Error("Missing file extracted (unexpected)")
+	}
+}
+
+// TestUnzipSlip : entries escaping the destination are refused
+func TestUnzipSlip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "evil.zip")
+	dest := filepath.Join(dir, "dest")
+	writeZip(t, src, map[string]string{"../evil": "evil"})
+
+	if err := pkg.Unzip(src, dest); err == nil {
+		t.Error("Zip slip extracted (unexpected)")
+	}
+
+	if checkFileExist(filepath.Join(dir, "evil")) {
+		t.Error("File written outside of the destination (unexpected)")
+	}
+}
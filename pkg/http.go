@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -8,16 +9,30 @@ import (
 )
 
 const (
-	retryAttempts = 3
-	retryDelay    = 10
+	defaultRetryAttempts = 3
+	defaultRetryDelay    = 10 * time.Second
 )
 
+// HTTPClient : the client used for every request, which refuses to send any in offline mode,
+// its retries and timeout are set by the http_retries, http_retry_delay and http_timeout settings
 func HTTPClient() *http.Client {
+	if Offline() {
+		return &http.Client{Transport: offlineTransport{}}
+	}
+
 	client := retryablehttp.NewClient()
-	client.RetryMax = retryAttempts
-	client.RetryWaitMin = time.Duration(retryDelay) * time.Second
+	client.RetryMax = configInt(settingHTTPRetries, defaultRetryAttempts)
+	client.RetryWaitMin = configDuration(settingHTTPRetryDelay, defaultRetryDelay)
 	client.RetryWaitMax = client.RetryWaitMin
+	client.HTTPClient.Timeout = configDuration(settingHTTPTimeout, 0)
 	client.Logger = nil // Disables DEBUG logs, failure log is kept.
 
 	return client.StandardClient()
 }
+
+// offlineTransport : fail every request with ErrOffline
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("refusing to get %s: %w", req.URL, ErrOffline)
+}
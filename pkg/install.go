@@ -1,122 +1,204 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/user"
 	"path/filepath"
 	"runtime"
 	"sort"
-	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
-	"github.com/hashicorp/terraform-config-inspect/tfconfig"
-	"github.com/rogpeppe/go-internal/lockedfile"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
 )
 
 const (
-	installFile    = "terraform"
-	installVersion = "terraform_"
-	installPath    = ".terraform.versions"
-	lockFilePath   = "/tmp/simple-tfswitch.lock"
+	installPath   = ".terraform.versions"
+	stagingPrefix = ".staging-"
+	staleStaging  = 24 * time.Hour
 )
 
-// getInstallLocation : get location where the terraform binary will be installed,
-// will create a directory in the home location if it does not exist
-func getInstallLocation() string {
-	/* get current user */
-	usr, errCurr := user.Current()
-	if errCurr != nil {
-		log.Fatal(errCurr)
+// getInstallLocation : get location where the terraform binary will be installed, set by the install_dir setting
+// or SIMPLE_TFSWITCH_INSTALL_DIR, will create a directory in the home location if it does not exist
+func getInstallLocation() (string, error) {
+	installLocation := configString(settingInstallDir)
+	if installLocation == "" {
+		return "", fmt.Errorf("%w: unable to find the home directory, set %s", ErrFilesystem, settingInstallDir)
 	}
 
-	userCommon := usr.HomeDir
-
-	/* For snapcraft users, SNAP_USER_COMMON environment variable is set by default.
-	 * tfswitch does not have permission to save to $HOME/.terraform.versions for snapcraft users
-	 * tfswitch will save binaries into $SNAP_USER_COMMON/.terraform.versions */
-	if os.Getenv("SNAP_USER_COMMON") != "" {
-		userCommon = os.Getenv("SNAP_USER_COMMON")
+	/* Create local installation directory if it does not exist */
+	if err := CreateDirIfNotExist(installLocation); err != nil {
+		return "", err
 	}
 
-	/* set installation location */
-	installLocation := filepath.Join(userCommon, installPath)
-
-	/* Create local installation directory if it does not exist */
-	CreateDirIfNotExist(installLocation)
+	return installLocation, nil
+}
 
-	return installLocation
+// Install : Install the provided version in the argument
+func Install(tfversion string, src Source) (string, error) {
+	return install(tfversion, src, nil)
 }
 
-func WaitForLockFile() (unlock func()) {
-	m := lockedfile.MutexAt(lockFilePath)
-	unlock, err := m.Lock()
+// install : install a version, its download being checked against the hash of lock as well when locked
+func install(tfversion string, src Source, lock *LockedVersion) (string, error) {
+	if !ValidVersionFormat(tfversion) {
+		return "", fmt.Errorf("%w: the provided terraform version format does not exist - %s", ErrInvalidVersion, tfversion)
+	}
+
+	installLocation, err := getInstallLocation() // get installation location -  this is where we will put our terraform binary file
 	if err != nil {
-		log.Errorf("there was a problem while trying to acquire lockfile %v", lockFilePath)
-		os.Exit(1)
+		return "", err
 	}
 
-	return unlock
-}
+	product := productOf(src)
 
-// Install : Install the provided version in the argument
-func Install(tfversion string, mirrorURL string) (string, error) {
-	if !ValidVersionFormat(tfversion) {
-		log.Errorf("The provided terraform version format does not exist - %s.", tfversion)
-		os.Exit(1)
+	/* if selected version already exist, there is nothing to wait for */
+	installFileVersionPath, err := installedPath(product, tfversion)
+	if err != nil {
+		return "", err
+	}
+	// the hash locked for the version is only checked when downloading: an installed binary was downloaded
+	// and verified once, and only the zip has a published hash to compare it with
+	if CheckFileExist(installFileVersionPath) {
+		markUsed(installFileVersionPath)
+
+		return installFileVersionPath, nil
 	}
 
 	// version install lockfile
-	unlock := WaitForLockFile()
+	unlock, err := lockVersion(product, tfversion)
+	if err != nil {
+		return "", err
+	}
 	defer unlock()
 
-	installLocation := getInstallLocation() // get installation location -  this is where we will put our terraform binary file
+	/* another process may have installed it while we were waiting for the lock */
+	if CheckFileExist(installFileVersionPath) {
+		markUsed(installFileVersionPath)
 
-	goarch := runtime.GOARCH
-	goos := runtime.GOOS
+		return installFileVersionPath, nil
+	}
+
+	mirrors := mirrorsOf(src)
+	if len(mirrors) == 0 {
+		return "", fmt.Errorf("%s %s is not installed: %w", product.Name, tfversion, ErrOffline)
+	}
 
-	/* check if selected version already downloaded */
-	installFileVersionPath := ConvertExecutableExt(filepath.Join(installLocation, installVersion+tfversion))
-	fileExist := CheckFileExist(installFileVersionPath)
+	if lock != nil && lock.hash(runtime.GOOS, runtime.GOARCH) == "" {
+		log.Warnf("No hash of %s %s for %s in %s, run simple-tfswitch lock -platform %s to record it",
+			product.Name, tfversion, Platform(runtime.GOOS, runtime.GOARCH), lock.File, Platform(runtime.GOOS, runtime.GOARCH))
+	}
 
-	/* if selected version already exist, */
-	if fileExist {
-		return installFileVersionPath, nil
+	cleanStaleStaging(installLocation)
+
+	/* download, verify and extract in a private staging directory, so that a crash or a concurrent */
+	/* install never leaves a partial binary where it could be used */
+	staging, err := os.MkdirTemp(installLocation, stagingPrefix)
+	if err != nil {
+		return "", opError(ErrFilesystem, "unable to create a staging directory", err)
 	}
+	defer os.RemoveAll(staging)
+
+	/* try the mirrors in order, each one providing both the zip and its checksums */
+	var installFilePath string
+	for _, mirror := range mirrors {
+		start := time.Now()
+		installFilePath, err = fetchBuild(mirror, tfversion, staging, lock)
+		fields := log.Fields{
+			logger.FieldVersion:  tfversion,
+			logger.FieldMirror:   mirror.String(),
+			logger.FieldDuration: time.Since(start).Seconds(),
+		}
+		if err == nil {
+			log.WithFields(fields).Debugf("Installing %s %s from mirror %s", product.Name, tfversion, mirror)
+			recordMirrorHealth(mirror, nil)
 
-	// if does not have slash - append slash
-	hasSlash := strings.HasSuffix(mirrorURL, "/")
-	if !hasSlash {
-		mirrorURL = fmt.Sprintf("%s/", mirrorURL)
+			break
+		}
+		if !failover(err) {
+			return "", err
+		}
+		log.WithFields(fields).Warnf("Unable to install %s %s from mirror %s: %v", product.Name, tfversion, mirror, err)
+		recordMirrorHealth(mirror, err)
+	}
+	if err != nil {
+		return "", err
 	}
 
-	/* if selected version already exist, */
-	/* proceed to download it from the hashicorp release page */
-	url := mirrorURL + tfversion + "/" + installVersion + tfversion + "_" + goos + "_" + goarch + ".zip"
-	zipFile, errDownload := DownloadFromURL(installLocation, url)
+	/* publish the binary under its version name - terraform_x.x.x - in one atomic rename */
+	if err := os.Rename(installFilePath, installFileVersionPath); err != nil {
+		return "", opError(ErrFilesystem, "unable to install "+product.Name+" "+tfversion, err)
+	}
+
+	return installFileVersionPath, nil
+}
 
-	/* If unable to download file from url, exit(1) immediately */
+// fetchBuild : download, verify and extract the binary of a version from one mirror into staging,
+// the download matching the hash of lock as well when locked
+func fetchBuild(src Source, tfversion string, staging string, lock *LockedVersion) (string, error) {
+	release, err := src.Release(tfversion)
+	if err != nil {
+		return "", err
+	}
+
+	build, err := release.Build(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+
+	/* proceed to download it from the release source */
+	// staging is in the install location, where partial downloads are kept for a later run
+	zipFile, errDownload := downloadArtifact(src, staging, filepath.Join(filepath.Dir(staging), partialDir), build.URL)
 	if errDownload != nil {
 		return "", errDownload
 	}
+	defer os.Remove(zipFile)
 
-	/* unzip the downloaded zipfile */
-	errUnzip := Unzip(zipFile, installLocation)
-	if errUnzip != nil {
-		log.Error("Unable to unzip downloaded zip file")
+	/* never install a zip which does not match the published checksums */
+	if err := verifyDownload(src, zipFile, release); err != nil {
+		return "", err
+	}
+	if err := verifyLockedHash(zipFile, lock); err != nil {
+		return "", err
+	}
 
-		return "", errUnzip
+	/* extract only the binary from the downloaded zipfile */
+	installFilePath, errUnzip := UnzipFile(zipFile, staging, ConvertExecutableExt(productOf(src).Name))
+	if errUnzip != nil {
+		return "", opError(ErrFilesystem, "unable to unzip "+zipFile, errUnzip)
 	}
 
-	/* rename unzipped file to terraform version name - terraform_x.x.x */
-	installFilePath := ConvertExecutableExt(filepath.Join(installLocation, installFile))
-	RenameFile(installFilePath, installFileVersionPath)
+	return installFilePath, nil
+}
 
-	/* remove zipped file to clear clutter */
-	RemoveFiles(zipFile)
+// failover : the error is specific to a mirror, another one may succeed
+func failover(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrChecksum) || errors.Is(err, ErrNoMatchingVersion) ||
+		errors.Is(err, errNotFound)
+}
 
-	return installFileVersionPath, nil
+// cleanStaleStaging : remove the staging directories left behind by crashed installs
+func cleanStaleStaging(installLocation string) {
+	stale, _ := filepath.Glob(filepath.Join(installLocation, stagingPrefix+"*"))
+	for _, dir := range stale {
+		if info, err := os.Stat(dir); err == nil && time.Since(info.ModTime()) > staleStaging {
+			log.Debugf("Removing stale staging directory %s", dir)
+			_ = os.RemoveAll(dir)
+		}
+	}
+}
+
+// verifyDownload : check the downloaded zip against the SHA256SUMS of its release
+func verifyDownload(src Source, zipFile string, release *Release) error {
+	sums, err := getSHA256Sums(src, release.ShasumsURL, release.SignatureURL)
+	if err != nil {
+		return fmt.Errorf("unable to get checksums from %s: %w", release.ShasumsURL, err)
+	}
+
+	return VerifyChecksum(zipFile, sums)
 }
 
 // ConvertExecutableExt : convert excutable with local OS extension
@@ -133,64 +215,139 @@ func ConvertExecutableExt(fpath string) string {
 	}
 }
 
-// install when tf file is provided
-func InstallTFProvidedModule(dir string, mirrorURL string) (string, error) {
-	module, _ := tfconfig.LoadModule(dir)
+// InstallTFProvidedModule : install the version required for dir, see ResolveTFProvidedModule,
+// the version used and why being logged whether it was already installed or not
+func InstallTFProvidedModule(dir string, src Source) (string, error) {
+	tfversion, req, err := ResolveTFProvidedModule(dir, src)
+	if err != nil {
+		return "", err
+	}
 
-	if len(module.RequiredCore) == 0 {
-		return "", fmt.Errorf("no required_versions found")
+	installed, err := install(tfversion, src, req.Lock)
+	if err != nil {
+		return "", err
 	}
-	tfconstraint := module.RequiredCore[0] // we skip duplicated definitions and use only first one
+	logResolved(productOf(src), tfversion, req)
+
+	AutoPrune(installed)
+
+	return installed, nil
+}
+
+// ResolveTFProvidedModule : the version to use for dir, without installing it,
+// along with the requirement it was resolved from, see FindVersionRequirement
+func ResolveTFProvidedModule(dir string, src Source) (string, *VersionRequirement, error) {
+	req, err := FindVersionRequirement(dir)
 
-	return installFromConstraint(&tfconstraint, mirrorURL), nil
+	return resolveRequirement(dir, req, err, src)
 }
 
-// install using a version constraint
-func installFromConstraint(tfconstraint *string, mirrorURL string) string {
-	listAll := true                            // set list all true - all versions including beta and rc will be displayed
-	tflist, _ := GetTFList(mirrorURL, listAll) // get list of versions
+// resolveRequirement : the version to use for dir from req and err, as found by FindVersionRequirement,
+// the default version policy applying when nothing is required
+func resolveRequirement(dir string, req *VersionRequirement, err error, src Source) (string, *VersionRequirement, error) {
+	if errors.Is(err, errNoVersionFound) {
+		req = &VersionRequirement{Constraint: DefaultVersionPolicy(), Source: defaultVersionEnv}
+		tfversion, err := resolveDefaultVersion(dir, src)
 
-	constrains, err := semver.NewConstraint(*tfconstraint) // NewConstraint returns a Constraints instance that a Version instance can be checked against
+		return tfversion, req, err
+	}
 	if err != nil {
-		log.Errorf("Error parsing constraint: %s, Please check constraint syntax on terraform file.", err)
-		os.Exit(1)
+		return "", nil, err
 	}
-	versions := make([]*semver.Version, len(tflist))
-	for i, tfvals := range tflist {
-		version, err := semver.NewVersion(tfvals) // NewVersion parses a given version and returns an instance of Version or an error if unable to parse the version.
+
+	if len(req.RequiredVersions) > 1 {
+		tflist, err := GetTFList(src, true)
 		if err != nil {
-			log.Errorf("Error parsing version: %s", err)
-			os.Exit(1)
+			return "", nil, err
 		}
+		if err := CheckRequiredVersions(req.RequiredVersions, tflist); err != nil {
+			return "", nil, err
+		}
+	}
+
+	tfversion, err := ResolveVersion(req.Constraint, src)
 
-		versions[i] = version
+	return tfversion, req, err
+}
+
+// logResolved : log the version of product used for a requirement, with the fields telling why it was chosen
+func logResolved(product *Product, tfversion string, req *VersionRequirement) {
+	log.WithFields(log.Fields{
+		logger.FieldVersion:    tfversion,
+		logger.FieldConstraint: req.Constraint,
+		logger.FieldSource:     req.Source,
+	}).Debugf("Using %s %s for %q from %s", product.Name, tfversion, req.Constraint, req.Source)
+}
+
+// InstallVersion : install an exact version, or the newest version matching a constraint
+func InstallVersion(tfconstraint string, src Source) (string, error) {
+	tfversion, err := ResolveVersion(tfconstraint, src)
+	if err != nil {
+		return "", err
 	}
 
-	sort.Sort(sort.Reverse(semver.Collection(versions)))
-	for _, element := range versions {
+	return Install(tfversion, src)
+}
+
+// ResolveVersion : the version to install for an exact version or a constraint, see ResolutionStrategy
+func ResolveVersion(tfconstraint string, src Source) (string, error) {
+	// an exact version does not need the list of versions
+	if ValidVersionFormat(tfconstraint) {
+		return tfconstraint, nil
+	}
+
+	// latest:<regex> asks for the newest version matching, whatever the strategy
+	strategy := ResolutionStrategy()
+	if isLatestRegex(tfconstraint) {
+		strategy = StrategyNewest
+	}
+
+	return resolveConstraint(tfconstraint, strategy, src)
+}
+
+// resolveConstraint : the version of the mirror matching a version constraint chosen by strategy,
+// pre-releases are candidates unless disabled by the pre_releases setting
+func resolveConstraint(tfconstraint string, strategy string, src Source) (string, error) {
+	tflist, err := GetTFList(src, PreReleases()) // get list of versions
+	if err != nil {
+		return "", err
+	}
+
+	matches, err := versionMatcher(tfconstraint)
+	if err != nil {
+		return "", err
+	}
+	versions := make([]*semver.Version, 0, len(tflist))
+	for _, tfvals := range tflist {
+		version, err := semver.NewVersion(tfvals) // NewVersion parses a given version and returns an instance of Version or an error if unable to parse the version.
+		if err != nil {
+			log.Debugf("Error parsing version: %s", err)
+
+			continue
+		}
+
 		// Validate a version against a constraint
-		if !constrains.Check(element) {
+		if !matches(version) {
 			continue
 		}
-		tfversion := element.String()
 
 		// check if version format is correct
-		if !ValidVersionFormat(tfversion) {
-			log.Debugf("Version not with invalid format %v", tfversion)
+		if !ValidVersionFormat(version.String()) {
+			log.Debugf("Version not with invalid format %v", version)
 
 			continue
 		}
-		out, err := Install(tfversion, mirrorURL)
-		if err != nil {
-			log.Printf("Error during install %v", err)
-			os.Exit(1)
-		}
 
-		return out
+		versions = append(versions, version)
 	}
 
-	log.Errorf("No version found to match constraint. Follow the README.md instructions for setup. https://github.com/terraform-tools/simple-tfswitch/blob/main/README.md")
-	os.Exit(1)
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+	if version := selectVersion(strategy, versions); version != nil {
+		log.Debugf("Resolved %q to %s with the %s strategy, among %d matching versions", tfconstraint, version, strategy, len(versions))
+
+		return version.String(), nil
+	}
 
-	return ""
+	return "", fmt.Errorf("%w: no version found to match constraint %s. Follow the README.md instructions for setup. "+
+		"https://github.com/terraform-tools/simple-tfswitch/blob/main/README.md", ErrNoMatchingVersion, tfconstraint)
 }
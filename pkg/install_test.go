@@ -1,9 +1,15 @@
 package pkg_test
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
+	"runtime"
 	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
 )
 
 // TestAddRecent : Create a file, check filename exist,
@@ -28,3 +34,115 @@ func TestInstall(t *testing.T) {
 		},
 	)
 }
+
+// installedEntries : the entries of the install directory, without the locks, caches and partial downloads
+func installedEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Name() != ".locks" && entry.Name() != ".cache" && entry.Name() != ".partial" {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+// TestInstallFromMirror : install a version from a local release server
+func TestInstallFromMirror(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+
+	installed, err := pkg.Install("1.2.3", srv.source())
+	if err != nil {
+		t.Fatalf("Unable to install: %v (unexpected)", err)
+	}
+
+	if filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_1.2.3") {
+		t.Errorf("Unexpected installed file %v", installed)
+	}
+
+	if !checkFileExist(installed) {
+		t.Errorf("Installed file %v does not exist (unexpected)", installed)
+	}
+
+	// only the versioned binary is left, no license, zip or staging directory
+	entries := installedEntries(t, filepath.Dir(installed))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the binary in the install directory, found %v (unexpected)", entries)
+	}
+}
+
+// TestInstallChecksumMismatch : a zip not matching the SHA256SUMS must not be installed
+func TestInstallChecksumMismatch(t *testing.T) {
+	installDir := t.TempDir()
+	t.Setenv("SNAP_USER_COMMON", installDir)
+	srv := newReleaseServer(t, "1.2.3")
+
+	zipName := fmt.Sprintf("terraform_1.2.3_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+	srv.files["1.2.3/"+zipName] = append(srv.files["1.2.3/"+zipName], 0)
+
+	if _, err := pkg.Install("1.2.3", srv.source()); !errors.Is(err, pkg.ErrChecksum) {
+		t.Fatalf("Tampered zip installed, or unexpected error %v", err)
+	}
+
+	entries := installedEntries(t, filepath.Join(installDir, ".terraform.versions"))
+	if len(entries) != 0 {
+		t.Errorf("Install directory should be empty, found %v (unexpected)", entries)
+	}
+}
+
+// TestInstallMissingChecksum : a zip without SHA256SUMS entry must not be installed
+func TestInstallMissingChecksum(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+	srv.setSHA256Sums(t, "1.2.3", []byte("0123  terraform_1.2.3_plan9_mips.zip\n"))
+
+	if _, err := pkg.Install("1.2.3", srv.source()); !errors.Is(err, pkg.ErrChecksum) {
+		t.Fatalf("Zip without checksum installed, or unexpected error %v", err)
+	}
+}
+
+// TestInstallBadSignature : SHA256SUMS not signed by a trusted key must be rejected with a SignatureError
+func TestInstallBadSignature(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+	shasums := "1.2.3/terraform_1.2.3_SHA256SUMS"
+	srv.files[shasums] = append([]byte("# tampered\n"), srv.files[shasums]...)
+
+	_, err := pkg.Install("1.2.3", srv.source())
+
+	var sigErr *pkg.SignatureError
+	if !errors.As(err, &sigErr) || !errors.Is(err, pkg.ErrChecksum) {
+		t.Fatalf("Expected a signature error, got %v (unexpected)", err)
+	}
+}
+
+// TestInstallErrors : failures are returned as errors of their kind, never exit
+func TestInstallErrors(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+
+	if _, err := pkg.Install("not-a-version", srv.source()); !errors.Is(err, pkg.ErrInvalidVersion) {
+		t.Errorf("Expected an invalid version error, got %v (unexpected)", err)
+	}
+
+	if _, err := pkg.InstallVersion("~> 2.0", srv.source()); !errors.Is(err, pkg.ErrNoMatchingVersion) {
+		t.Errorf("Expected a no matching version error, got %v (unexpected)", err)
+	}
+
+	if _, err := pkg.Install("1.2.4", srv.source()); !errors.Is(err, pkg.ErrNetwork) {
+		t.Errorf("Expected a network error for a missing release, got %v (unexpected)", err)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "1")
+	if _, err := pkg.Install("1.2.3", srv.source()); !errors.Is(err, pkg.ErrOffline) || !errors.Is(err, pkg.ErrNetwork) {
+		t.Errorf("Expected an offline network error, got %v (unexpected)", err)
+	}
+}
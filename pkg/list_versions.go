@@ -1,84 +1,17 @@
 package pkg
 
 import (
-	"fmt"
-	"io"
-	"os"
 	"regexp"
-	"strings"
-
-	log "github.com/sirupsen/logrus"
 )
 
-type tfVersionList struct {
-	tflist []string
-}
-
-// GetTFList :  Get the list of available terraform version given the hashicorp url
-func GetTFList(mirrorURL string, preRelease bool) ([]string, error) {
-	result, err := GetTFURLBody(mirrorURL)
+// GetTFList :  Get the list of available terraform version of a release source, newest first
+func GetTFList(src Source, preRelease bool) ([]string, error) {
+	catalog, err := GetCatalog(src)
 	if err != nil {
 		return nil, err
 	}
 
-	var tfVersionList tfVersionList
-	var semver string
-	if preRelease {
-		// Getting versions from body; should return match /X.X.X-@/ where X is a number,@ is a word character between a-z or A-Z
-		semver = `\/(\d+\.\d+\.\d+)(-[a-zA-z]+\d*)?\/\"`
-	} else if !preRelease {
-		// Getting versions from body; should return match /X.X.X/ where X is a number
-		// without the ending '"' pre-release folders would be tried and break.
-		semver = `\/(\d+\.\d+\.\d+)\/\"`
-	}
-	r, _ := regexp.Compile(semver)
-	for i := range result {
-		if r.MatchString(result[i]) {
-			str := r.FindString(result[i])
-			trimstr := strings.Trim(str, "/\"") // remove "/" from /X.X.X/
-			tfVersionList.tflist = append(tfVersionList.tflist, trimstr)
-		}
-	}
-
-	if len(tfVersionList.tflist) == 0 {
-		log.Errorf("Cannot get list from mirror: %s", mirrorURL)
-	}
-
-	return tfVersionList.tflist, nil
-}
-
-// GetTFURLBody : Get list of terraform versions from hashicorp releases
-func GetTFURLBody(mirrorURL string) ([]string, error) {
-	hasSlash := strings.HasSuffix(mirrorURL, "/")
-	if !hasSlash { // if does not have slash - append slash
-		mirrorURL = fmt.Sprintf("%s/", mirrorURL)
-	}
-	resp, errURL := HTTPClient().Get(mirrorURL)
-	if errURL != nil {
-		log.Printf("Getting url: %v", errURL)
-		os.Exit(1)
-
-		return nil, errURL
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Printf("Retrieving contents from url: %s", mirrorURL)
-
-		return nil, fmt.Errorf("retrieving contents from url: %s", mirrorURL)
-	}
-
-	body, errBody := io.ReadAll(resp.Body)
-	if errBody != nil {
-		log.Printf("Reading body: %v", errBody)
-
-		return nil, errBody
-	}
-
-	bodyString := string(body)
-	result := strings.Split(bodyString, "\n")
-
-	return result, nil
+	return catalog.Versions(preRelease), nil
 }
 
 // ValidVersionFormat : returns valid version format
@@ -2,6 +2,7 @@ package pkg_test
 
 import (
 	"log"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -15,7 +16,7 @@ const (
 // TestGetTFList : Get list from hashicorp
 func TestGetTFList(t *testing.T) {
 	listAll := true
-	list, _ := pkg.GetTFList(hashiURL, listAll)
+	list, _ := pkg.GetTFList(&pkg.HTTPSource{URL: hashiURL}, listAll)
 
 	val := "0.1.0"
 	var exists bool
@@ -132,3 +133,26 @@ func TestValidVersionFormat(t *testing.T) {
 		log.Fatalf("Failed to verify version format: %s\n", version)
 	}
 }
+
+// TestDeprecatedURLFunctions : the functions taking a mirror url still list and install from it
+func TestDeprecatedURLFunctions(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3", "1.3.0")
+	srv.noIndex = true // an index page to read
+	mirrorURL := srv.URL + "/terraform"
+
+	list, err := pkg.GetTFListFromURL(mirrorURL, false)
+	if err != nil || !reflect.DeepEqual(list, []string{"1.3.0", "1.2.3"}) {
+		t.Errorf("Listed %v, %v from %s (unexpected)", list, err, mirrorURL)
+	}
+
+	lines, err := pkg.GetTFURLBody(mirrorURL)
+	if err != nil || len(lines) == 0 {
+		t.Errorf("Read %v, %v from %s (unexpected)", lines, err, mirrorURL)
+	}
+
+	installed, err := pkg.InstallFromURL("1.2.3", mirrorURL)
+	if err != nil || filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_1.2.3") {
+		t.Errorf("Installed %v, %v from %s (unexpected)", installed, err, mirrorURL)
+	}
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// GetLocalTFList : Get the list of versions of the current product already installed, newest first
+func GetLocalTFList() ([]string, error) {
+	return localVersions(CurrentProduct())
+}
+
+// localVersions : the versions of product already installed, newest first
+func localVersions(product *Product) ([]string, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(installLocation)
+	if err != nil {
+		return nil, opError(ErrFilesystem, "unable to list the installed versions", err)
+	}
+
+	versions := []*semver.Version{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".exe")
+		if entry.IsDir() || !strings.HasPrefix(name, product.installedName("")) {
+			continue
+		}
+
+		version := strings.TrimPrefix(name, product.installedName(""))
+		if !ValidVersionFormat(version) {
+			continue
+		}
+
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	list := make([]string, len(versions))
+	for i, v := range versions {
+		list[i] = v.String()
+	}
+
+	return list, nil
+}
+
+// InstalledPath : where a version of the current product is installed, whether it is installed or not
+func InstalledPath(tfversion string) (string, error) {
+	return installedPath(CurrentProduct(), tfversion)
+}
+
+// installedPath : where a version of product is installed, whether it is installed or not
+func installedPath(product *Product, tfversion string) (string, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return ConvertExecutableExt(filepath.Join(installLocation, product.installedName(tfversion))), nil
+}
+
+// Uninstall : remove an installed version of the current product, return the removed file
+func Uninstall(tfversion string) (string, error) {
+	product := CurrentProduct()
+	if !ValidVersionFormat(tfversion) {
+		return "", fmt.Errorf("%w: invalid %s version format: %s", ErrInvalidVersion, product.Name, tfversion)
+	}
+
+	// never remove a binary while another process installs it
+	unlock, err := lockVersion(product, tfversion)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	path, err := installedPath(product, tfversion)
+	if err != nil {
+		return "", err
+	}
+	if !CheckFileExist(path) {
+		return "", fmt.Errorf("%s %s is not installed: %w", product.Name, tfversion, os.ErrNotExist)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", opError(ErrFilesystem, "unable to uninstall "+product.Name+" "+tfversion, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rogpeppe/go-internal/lockedfile"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	locksDir           = ".locks"
+	lockExt            = ".lock"
+	lockHolderExt      = ".holder"
+	lockTimeoutEnv     = "SIMPLE_TFSWITCH_LOCK_TIMEOUT"
+	defaultLockTimeout = 10 * time.Minute
+	lockWaitNotice     = 2 * time.Second
+)
+
+// LockHolder : the process holding the lock of a version, as recorded when it took it
+type LockHolder struct {
+	Product string    `json:"product"`
+	Version string    `json:"version"`
+	PID     int       `json:"pid"`
+	Since   time.Time `json:"since"`
+}
+
+// String : a human readable description of the holder
+func (h *LockHolder) String() string {
+	return fmt.Sprintf("pid %d since %s", h.PID, h.Since.Format(time.RFC3339))
+}
+
+// LockTimeoutError : the lock of a version could not be taken in time
+type LockTimeoutError struct {
+	Product string
+	Version string
+	Timeout time.Duration
+	Holder  *LockHolder
+}
+
+func (e *LockTimeoutError) Error() string {
+	msg := fmt.Sprintf("timed out after %v waiting for the lock of %s %s", e.Timeout, e.Product, e.Version)
+	if e.Holder != nil {
+		msg += ", held by " + e.Holder.String()
+	}
+
+	return msg + " (see " + lockTimeoutEnv + ")"
+}
+
+// Is : a LockTimeoutError is an ErrLockTimeout
+func (e *LockTimeoutError) Is(target error) bool {
+	return target == ErrLockTimeout
+}
+
+// LockTimeout : how long to wait for the lock of a version,
+// set by the lock_timeout setting or SIMPLE_TFSWITCH_LOCK_TIMEOUT as a duration (10m, 30s, ...), 0 to wait forever
+func LockTimeout() time.Duration {
+	return configDuration(settingLockTimeout, defaultLockTimeout)
+}
+
+// lockPath : the lock file of a version of product, in the install location of the current user
+func lockPath(product *Product, tfversion string) (string, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(installLocation, locksDir, product.installedName(tfversion)+lockExt), nil
+}
+
+// LockVersion : take the lock of a version of the current product, held while it is installed or removed.
+// Locks are per user, per product and per version, so processes working on other versions never wait.
+func LockVersion(tfversion string) (unlock func(), err error) {
+	return lockVersion(CurrentProduct(), tfversion)
+}
+
+// lockVersion : take the lock of a version of product, see LockVersion
+func lockVersion(product *Product, tfversion string) (unlock func(), err error) {
+	path, err := lockPath(product, tfversion)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, opError(ErrFilesystem, "unable to create the locks directory", err)
+	}
+
+	type locked struct {
+		unlock func()
+		err    error
+	}
+	result := make(chan locked, 1)
+	go func() {
+		unlock, err := lockedfile.MutexAt(path).Lock()
+		result <- locked{unlock, err}
+	}()
+
+	var timeout <-chan time.Time
+	if d := LockTimeout(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	notice := time.NewTimer(lockWaitNotice)
+	defer notice.Stop()
+
+	for {
+		select {
+		case r := <-result:
+			if r.err != nil {
+				return nil, opError(ErrFilesystem, "unable to acquire lockfile "+path, r.err)
+			}
+			writeLockHolder(path)
+
+			return func() {
+				_ = os.Remove(path + lockHolderExt)
+				r.unlock()
+			}, nil
+		case <-notice.C:
+			if holder, err := readLockHolder(path); err == nil {
+				log.Infof("Waiting for the lock of %s %s, held by %s", product.Name, tfversion, holder)
+			}
+		case <-timeout:
+			// release the lock if it is eventually taken, nobody will use it
+			go func() {
+				if r := <-result; r.err == nil {
+					r.unlock()
+				}
+			}()
+			holder, _ := readLockHolder(path)
+
+			return nil, &LockTimeoutError{Product: product.Name, Version: tfversion, Timeout: LockTimeout(), Holder: holder}
+		}
+	}
+}
+
+// writeLockHolder : record the current process as the holder of a lock, for diagnostics only
+func writeLockHolder(path string) {
+	holder := fmt.Sprintf("%d %s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+	if err := os.WriteFile(path+lockHolderExt, []byte(holder), 0o644); err != nil {
+		log.Debugf("Unable to record the holder of the lock %s: %v", path, err)
+	}
+}
+
+// readLockHolder : the holder recorded for a lock, of the product and version named by the lock file
+func readLockHolder(path string) (*LockHolder, error) {
+	content, err := os.ReadFile(path + lockHolderExt)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("malformed lock holder %s", path+lockHolderExt)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	since, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	holder := &LockHolder{PID: pid, Since: since}
+	name := strings.TrimSuffix(filepath.Base(path), lockExt)
+	for _, p := range Products() {
+		if version := strings.TrimPrefix(name, p.installedName("")); version != name {
+			holder.Product, holder.Version = p.Name, version
+		}
+	}
+
+	return holder, nil
+}
+
+// GetLockHolders : the processes currently holding a version lock of the current user.
+// A holder left by a process which was killed is listed until the lock is taken again.
+func GetLockHolders() ([]LockHolder, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	holderFiles, err := filepath.Glob(filepath.Join(installLocation, locksDir, "*"+lockExt+lockHolderExt))
+	if err != nil {
+		return nil, err
+	}
+
+	holders := []LockHolder{}
+	for _, file := range holderFiles {
+		holder, err := readLockHolder(strings.TrimSuffix(file, lockHolderExt))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Debugf("Skipping lock holder %s: %v", file, err)
+
+			continue
+		}
+		holders = append(holders, *holder)
+	}
+
+	return holders, nil
+}
@@ -0,0 +1,356 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// LockFileName : the lock file of a repository, written by the lock command
+	LockFileName = ".simple-tfswitch.lock.hcl"
+
+	lockFileHeader       = "# This file is maintained by \"simple-tfswitch lock\", commit it so that everyone runs the same version.\n\n"
+	defaultLockPlatforms = "darwin_amd64,darwin_arm64,linux_amd64,linux_arm64,windows_amd64"
+)
+
+// LockedVersion : the exact version of a product locked for a repository, with the sha256 of its zip for each platform
+type LockedVersion struct {
+	Product    string            `json:"product"    hcl:"product,label"`
+	Version    string            `json:"version"    hcl:"version"`
+	Constraint string            `json:"constraint" hcl:"constraint,optional"`
+	Hashes     map[string]string `json:"hashes"     hcl:"hashes"`
+
+	// File : the lock file the version is locked in
+	File string `json:"file"`
+}
+
+// lockFileContent : the versions locked in a lock file, one block per product
+type lockFileContent struct {
+	Products []*LockedVersion `hcl:"product,block"`
+}
+
+// Platform : the os_arch name of a platform, as in the names of the release zips
+func Platform(goos string, goarch string) string {
+	return goos + "_" + goarch
+}
+
+// LockPlatforms : the platforms the lock command records by default, set by the lock_platforms setting
+// or SIMPLE_TFSWITCH_LOCK_PLATFORMS
+func LockPlatforms() []string {
+	return withPlatform(configList(settingLockPlatforms))
+}
+
+// withPlatform : platforms sorted without duplicates, with the ones given
+func withPlatform(platforms []string, more ...string) []string {
+	set := map[string]bool{}
+	for _, p := range append(platforms, more...) {
+		set[p] = true
+	}
+
+	sorted := make([]string, 0, len(set))
+	for p := range set {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// hash : the sha256 locked for the zip of goos/goarch, "" when none
+func (l *LockedVersion) hash(goos string, goarch string) string {
+	if l == nil {
+		return ""
+	}
+
+	return l.Hashes[Platform(goos, goarch)]
+}
+
+// platforms : the platforms with a locked hash, sorted
+func (l *LockedVersion) platforms() []string {
+	platforms := make([]string, 0, len(l.Hashes))
+	for p := range l.Hashes {
+		platforms = append(platforms, p)
+	}
+
+	return withPlatform(platforms)
+}
+
+// findLockFile : the closest lock file from dir up to the repository root, "" when none
+func findLockFile(dir string) string {
+	for {
+		if path := filepath.Join(dir, LockFileName); CheckFileExist(path) {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if isRepositoryRoot(dir) || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readLockFile : the versions locked in the lock file at path, none when it does not exist
+func readLockFile(path string) ([]*LockedVersion, error) {
+	if !CheckFileExist(path) {
+		return nil, nil
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrConfig, diags.Error())
+	}
+
+	content := &lockFileContent{}
+	if diags := gohcl.DecodeBody(file.Body, nil, content); diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrConfig, diags.Error())
+	}
+	for _, l := range content.Products {
+		if !ValidVersionFormat(l.Version) {
+			return nil, fmt.Errorf("%w: invalid %s version %q locked in %s", ErrConfig, l.Product, l.Version, path)
+		}
+		l.File = path
+	}
+
+	return content.Products, nil
+}
+
+// writeLockFile : replace the lock file at path with the versions locked, sorted by product
+func writeLockFile(path string, locks []*LockedVersion) error {
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Product < locks[j].Product
+	})
+
+	file := hclwrite.NewEmptyFile()
+	for i, l := range locks {
+		if i > 0 {
+			file.Body().AppendNewline()
+		}
+		body := file.Body().AppendNewBlock("product", []string{l.Product}).Body()
+		body.SetAttributeValue("version", cty.StringVal(l.Version))
+		if l.Constraint != "" {
+			body.SetAttributeValue("constraint", cty.StringVal(l.Constraint))
+		}
+		hashes := map[string]cty.Value{}
+		for platform, sum := range l.Hashes {
+			hashes[platform] = cty.StringVal(sum)
+		}
+		body.SetAttributeValue("hashes", cty.MapVal(hashes))
+	}
+
+	content := append([]byte(lockFileHeader), hclwrite.Format(file.Bytes())...)
+	if err := writeFileAtomic(path, content); err != nil {
+		return opError(ErrFilesystem, "unable to write "+path, err)
+	}
+
+	return nil
+}
+
+// findLockedVersion : the version of product locked for dir, nil when none
+func findLockedVersion(dir string, product *Product) (*LockedVersion, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	path := findLockFile(dir)
+	if path == "" {
+		return nil, nil
+	}
+
+	locks, err := readLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locks {
+		if l.Product == product.Name {
+			return l, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applyLock : the requirement of dir once its lock file applies, the locked version when it satisfies req,
+// req itself when nothing is locked. req is nil when dir requires nothing, then the locked version is used as is.
+func applyLock(dir string, req *VersionRequirement) (*VersionRequirement, error) {
+	lock, err := findLockedVersion(dir, ProductFor(dir))
+	if err != nil || lock == nil {
+		return req, err
+	}
+
+	locked := &VersionRequirement{Constraint: lock.Version, Source: lock.File + " (" + lock.Version + ")", Lock: lock}
+	if req == nil {
+		return locked, nil
+	}
+
+	ok, err := satisfies(lock.Version, req.Constraint)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s locked in %s does not satisfy %s required by %s, run simple-tfswitch lock to update it",
+			ErrNoMatchingVersion, lock.Product, lock.Version, lock.File, req.Constraint, req.Source)
+	}
+	locked.Source += " satisfying " + req.Constraint + " from " + req.Source
+
+	return locked, nil
+}
+
+// satisfies : whether version matches constraint
+func satisfies(version string, constraint string) (bool, error) {
+	matches, err := versionMatcher(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, opError(ErrInvalidVersion, fmt.Sprintf("error parsing version %q", version), err)
+	}
+
+	return matches(v), nil
+}
+
+// UpdateLockFile : lock the version dir resolves to, with the hashes of its zips for platforms and the current one,
+// in the closest lock file up to the repository root, or else a new one in dir, so that the modules of a monorepo
+// lock their own versions. The version already locked is kept while it satisfies the requirement of dir,
+// unless upgrade is set, the platforms it has hashes for are kept.
+func UpdateLockFile(dir string, platforms []string, upgrade bool, src Source) (*LockedVersion, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	platforms = withPlatform(platforms, Platform(runtime.GOOS, runtime.GOARCH))
+
+	product := ProductFor(dir)
+	if productOf(src).Name != product.Name {
+		if src, err = NewMirrors(product.Mirrors(), product); err != nil {
+			return nil, err
+		}
+	}
+
+	path := findLockFile(dir)
+	if path == "" {
+		path = filepath.Join(dir, LockFileName)
+	}
+	locks, err := readLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the requirement of the repository, the lock and TFSWITCH_VERSION being ignored
+	req, errFind := repositoryRequirement(dir)
+	if errFind != nil && !errors.Is(errFind, errNoVersionFound) {
+		return nil, errFind
+	}
+
+	lock := &LockedVersion{Product: product.Name, File: path}
+	if req != nil {
+		lock.Constraint = req.Constraint
+	}
+
+	kept := -1
+	for i, l := range locks {
+		if l.Product != product.Name {
+			continue
+		}
+		kept = i
+		platforms = withPlatform(platforms, l.platforms()...)
+		if upgrade {
+			break
+		}
+		if req != nil {
+			if ok, err := satisfies(l.Version, req.Constraint); err != nil || !ok {
+				log.Infof("%s %s locked in %s does not satisfy %s any more", l.Product, l.Version, path, req.Constraint)
+
+				break
+			}
+		}
+		lock.Version = l.Version
+	}
+
+	if lock.Version == "" {
+		if lock.Version, _, err = resolveRequirement(dir, req, errFind, src); err != nil {
+			return nil, err
+		}
+	}
+
+	if lock.Hashes, err = releaseHashes(src, lock.Version, platforms); err != nil {
+		return nil, err
+	}
+
+	if kept >= 0 {
+		locks[kept] = lock
+	} else {
+		locks = append(locks, lock)
+	}
+	if err := writeLockFile(path, locks); err != nil {
+		return nil, err
+	}
+	log.Debugf("Locked %s %s in %s for %s", product.Name, lock.Version, path, strings.Join(platforms, ", "))
+
+	return lock, nil
+}
+
+// releaseHashes : the sha256 of the zips of a version for each of platforms it is built for, from its verified SHA256SUMS
+func releaseHashes(src Source, version string, platforms []string) (map[string]string, error) {
+	release, err := src.Release(version)
+	if err != nil {
+		return nil, err
+	}
+
+	sums, err := getSHA256Sums(src, release.ShasumsURL, release.SignatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get checksums from %s: %w", release.ShasumsURL, err)
+	}
+
+	hashes := map[string]string{}
+	for _, platform := range platforms {
+		goos, goarch, ok := strings.Cut(platform, "_")
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid platform %q, expected os_arch as linux_amd64", ErrConfig, platform)
+		}
+
+		// a platform a version is not built for is left out, the others can still be locked
+		build, err := release.Build(goos, goarch)
+		if err != nil {
+			log.Warnf("Not locking %s %s for %s: %v", release.Name, version, platform, err)
+
+			continue
+		}
+		sum, ok := sums[build.Filename]
+		if !ok {
+			log.Warnf("Not locking %s %s for %s: no checksum of %s", release.Name, version, platform, build.Filename)
+
+			continue
+		}
+		hashes[platform] = sum
+	}
+
+	return hashes, nil
+}
+
+// verifyLockedHash : check the downloaded zip of a version against the hash locked for the current platform
+func verifyLockedHash(zipFile string, lock *LockedVersion) error {
+	expected := lock.hash(runtime.GOOS, runtime.GOARCH)
+	if expected == "" {
+		return nil
+	}
+
+	if err := VerifyChecksum(zipFile, map[string]string{filepath.Base(zipFile): expected}); err != nil {
+		return fmt.Errorf("not the build locked in %s: %w", lock.File, err)
+	}
+
+	return nil
+}
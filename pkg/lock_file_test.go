@@ -0,0 +1,118 @@
+package pkg_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestUpdateLockFile : the lock file created in the module keeps the version it locked, with the hashes
+// of its builds, until upgraded, and is preferred to resolving the requirement while it satisfies it
+func TestUpdateLockFile(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.0", "1.2.5")
+	srv.addBuild(t, "1.2.0", "plan9", "arm")
+	srv.addBuild(t, "1.2.5", "plan9", "arm")
+	_, module := newRepository(t)
+	path := filepath.Join(module, pkg.LockFileName)
+	current := pkg.Platform(runtime.GOOS, runtime.GOARCH)
+
+	expectLock := func(lock *pkg.LockedVersion, err error, version string) {
+		t.Helper()
+
+		if err != nil {
+			t.Fatalf("Unable to lock: %v (unexpected)", err)
+		}
+		if lock.Version != version || lock.File != path || lock.Constraint != "~> 1.2.0" {
+			t.Errorf("Expected %s locked in %s, got %+v (unexpected)", version, path, lock)
+		}
+		sum := sha256.Sum256([]byte("terraform_" + version + "_plan9_arm.zip"))
+		if len(lock.Hashes) != 2 || lock.Hashes[current] == "" || lock.Hashes["plan9_arm"] != hex.EncodeToString(sum[:]) {
+			t.Errorf("Expected the hashes of %s and plan9_arm, got %v (unexpected)", current, lock.Hashes)
+		}
+
+		req, err := pkg.FindVersionRequirement(module)
+		if err != nil || req.Constraint != version || req.Lock == nil || req.Lock.Hashes[current] != lock.Hashes[current] {
+			t.Errorf("Expected the requirement to be the locked %s, got %+v, %v (unexpected)", version, req, err)
+		}
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", pkg.StrategyOldest)
+	lock, err := pkg.UpdateLockFile(module, []string{"plan9_arm"}, false, srv.source())
+	expectLock(lock, err, "1.2.0")
+
+	// the locked version and its platforms are kept, whatever the resolver would choose now
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", pkg.StrategyNewest)
+	lock, err = pkg.UpdateLockFile(module, nil, false, srv.source())
+	expectLock(lock, err, "1.2.0")
+
+	lock, err = pkg.UpdateLockFile(module, nil, true, srv.source())
+	expectLock(lock, err, "1.2.5")
+
+	installed, err := pkg.InstallTFProvidedModule(module, srv.source())
+	if err != nil || filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_1.2.5") {
+		t.Errorf("Expected the locked 1.2.5 to be installed, got %s, %v (unexpected)", installed, err)
+	}
+
+	// a requirement the locked version does not satisfy any more
+	writeFile(t, filepath.Join(module, "main.tf"), "terraform {\n  required_version = \"~> 1.3.0\"\n}\n")
+	if _, err := pkg.FindVersionRequirement(module); !errors.Is(err, pkg.ErrNoMatchingVersion) {
+		t.Errorf("Expected a no matching version error, got %v (unexpected)", err)
+	}
+
+	// the TFSWITCH_VERSION override wins over the lock file
+	t.Setenv("TFSWITCH_VERSION", "1.2.0")
+	if req, err := pkg.FindVersionRequirement(module); err != nil || req.Constraint != "1.2.0" || req.Source != "TFSWITCH_VERSION" || req.Lock != nil {
+		t.Errorf("Expected the version of TFSWITCH_VERSION, got %+v, %v (unexpected)", req, err)
+	}
+}
+
+// TestUpdateLockFileRepositoryOnly : the version locked is the one the repository requires, TFSWITCH_VERSION aside,
+// and a platform without a build is left out of the hashes
+func TestUpdateLockFileRepositoryOnly(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", pkg.StrategyNewest)
+	t.Setenv("TFSWITCH_VERSION", "1.2.0")
+	srv := newReleaseServer(t, "1.2.0", "1.2.5")
+	_, module := newRepository(t)
+	current := pkg.Platform(runtime.GOOS, runtime.GOARCH)
+
+	lock, err := pkg.UpdateLockFile(module, []string{"plan9_arm"}, false, srv.source())
+	if err != nil {
+		t.Fatalf("Unable to lock: %v (unexpected)", err)
+	}
+	if lock.Version != "1.2.5" || lock.Constraint != "~> 1.2.0" {
+		t.Errorf("Expected 1.2.5 locked for ~> 1.2.0, got %+v (unexpected)", lock)
+	}
+	if len(lock.Hashes) != 1 || lock.Hashes[current] == "" {
+		t.Errorf("Expected only the hash of %s, got %v (unexpected)", current, lock.Hashes)
+	}
+}
+
+// TestLockedHashMismatch : a download which is not the build locked is refused, even with valid checksums
+func TestLockedHashMismatch(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.5")
+	repo, module := newRepository(t)
+
+	writeFile(t, filepath.Join(repo, pkg.LockFileName), `
+product "terraform" {
+  version = "1.2.5"
+  hashes = {
+    `+pkg.Platform(runtime.GOOS, runtime.GOARCH)+` = "0123456789abcdef"
+  }
+}
+`)
+
+	if _, err := pkg.InstallTFProvidedModule(module, srv.source()); !errors.Is(err, pkg.ErrChecksum) {
+		t.Errorf("Expected a checksum error, got %v (unexpected)", err)
+	}
+	if local, err := pkg.GetLocalTFList(); err != nil || len(local) != 0 {
+		t.Errorf("Expected nothing installed, got %v, %v (unexpected)", local, err)
+	}
+}
@@ -0,0 +1,70 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestLockVersion : a held lock times out with its holder, other versions are not blocked
+func TestLockVersion(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_LOCK_TIMEOUT", "100ms")
+
+	unlock, err := pkg.LockVersion("1.3.0")
+	if err != nil {
+		t.Fatalf("Unable to lock 1.3.0: %v (unexpected)", err)
+	}
+
+	holders, err := pkg.GetLockHolders()
+	if err != nil || len(holders) != 1 || holders[0].Version != "1.3.0" || holders[0].PID != os.Getpid() {
+		t.Errorf("Expected 1.3.0 to be held by pid %d, got %v, %v (unexpected)", os.Getpid(), holders, err)
+	}
+
+	other, err := pkg.LockVersion("1.2.0")
+	if err != nil {
+		t.Fatalf("Unable to lock 1.2.0 while 1.3.0 is locked: %v (unexpected)", err)
+	}
+	other()
+
+	_, err = pkg.LockVersion("1.3.0")
+	var timeoutErr *pkg.LockTimeoutError
+	if !errors.Is(err, pkg.ErrLockTimeout) || !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a lock timeout, got %v (unexpected)", err)
+	}
+	if timeoutErr.Holder == nil || timeoutErr.Holder.PID != os.Getpid() {
+		t.Errorf("Expected the timeout to report pid %d, got %v (unexpected)", os.Getpid(), err)
+	}
+
+	unlock()
+
+	if holders, _ := pkg.GetLockHolders(); len(holders) != 0 {
+		t.Errorf("Expected no lock holder once released, got %v (unexpected)", holders)
+	}
+
+	unlock, err = pkg.LockVersion("1.3.0")
+	if err != nil {
+		t.Fatalf("Unable to lock 1.3.0 once released: %v (unexpected)", err)
+	}
+	unlock()
+}
+
+// TestInstallInstalledWithoutLock : an installed version is used even while its lock is held
+func TestInstallInstalledWithoutLock(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_LOCK_TIMEOUT", "100ms")
+	fakeInstalled(t, "1.3.0", 10, 1)
+
+	unlock, err := pkg.LockVersion("1.3.0")
+	if err != nil {
+		t.Fatalf("Unable to lock 1.3.0: %v (unexpected)", err)
+	}
+	defer unlock()
+
+	path, err := pkg.Install("1.3.0", &pkg.HTTPSource{URL: "http://127.0.0.1:0/terraform/"})
+	if expected, _ := pkg.InstalledPath("1.3.0"); err != nil || path != expected {
+		t.Errorf("Expected the installed 1.3.0, got %q, %v (unexpected)", path, err)
+	}
+}
@@ -1,20 +1,79 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// FormatText : human readable lines, the default
+	FormatText = "text"
+	// FormatJSON : one JSON object per line, for log shippers
+	FormatJSON = "json"
+
+	// DebugEnv : any value turns on the debug level, when the log_level setting is not set
+	DebugEnv = "SIMPLE_TFSWITCH_DEBUG"
+
+	// FieldVersion : the field holding the version of terraform a message is about
+	FieldVersion = "version"
+	// FieldConstraint : the field holding the version constraint being resolved
+	FieldConstraint = "constraint"
+	// FieldSource : the field holding where the constraint comes from, as "required_version of main.tf (~> 1.5)"
+	FieldSource = "source"
+	// FieldMirror : the field holding the mirror a version is installed from
+	FieldMirror = "mirror"
+	// FieldDuration : the field holding how long an operation took, in seconds
+	FieldDuration = "duration"
+)
+
+// Options : where and how the logs of simple-tfswitch are written
+type Options struct {
+	Level  log.Level
+	Format string
+	// File : the file logs are appended to, instead of stderr which terraform writes to as well, "" for stderr
+	File string
+}
+
+// Setup : the logging used until the configuration is read, text on stderr,
+// at the debug level when SIMPLE_TFSWITCH_DEBUG is set
 func Setup() {
-	if os.Getenv("SIMPLE_TFSWITCH_DEBUG") != "" {
-		log.SetLevel(log.DebugLevel)
+	level := log.InfoLevel
+	if os.Getenv(DebugEnv) != "" {
+		level = log.DebugLevel
+	}
+
+	_ = Configure(Options{Level: level, Format: FormatText})
+}
+
+// Configure : log with opts, the file and line of each message being only reported at the debug and trace levels
+func Configure(opts Options) error {
+	switch opts.Format {
+	case FormatText, "":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp:          true,
+			DisableLevelTruncation: true,
+		})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q, expected %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+
+	if opts.File == "" {
+		log.SetOutput(os.Stderr)
+	} else {
+		// left open for the life of the process, as stderr
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gosec // a log file readable by others, as stderr
+		if err != nil {
+			return fmt.Errorf("unable to open the log file: %w", err)
+		}
+		log.SetOutput(file)
 	}
 
-	log.SetReportCaller(true)
+	log.SetLevel(opts.Level)
+	log.SetReportCaller(opts.Level >= log.DebugLevel)
 
-	log.SetFormatter(&log.TextFormatter{
-		FullTimestamp:          true,
-		DisableLevelTruncation: true,
-	})
+	return nil
 }
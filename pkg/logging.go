@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
+)
+
+// defaultLogLevel : info, debug when SIMPLE_TFSWITCH_DEBUG is set
+func defaultLogLevel() string {
+	if os.Getenv(logger.DebugEnv) != "" {
+		return log.DebugLevel.String()
+	}
+
+	return log.InfoLevel.String()
+}
+
+// LogOptions : how to log, from the log_level, log_format and log_file settings,
+// an invalid level or format being reported and replaced by its default
+func LogOptions() logger.Options {
+	opts := logger.Options{
+		Level:  log.InfoLevel,
+		Format: configString(settingLogFormat),
+		File:   configString(settingLogFile),
+	}
+
+	s := configSetting(settingLogLevel)
+	level, err := log.ParseLevel(s.Value)
+	if err == nil && level >= log.ErrorLevel {
+		opts.Level = level
+	} else {
+		log.Warnf("Invalid %s %q from %s, expected error, warn, info, debug or trace, using %v", s.Name, s.Value, s.Origin, opts.Level)
+	}
+
+	if opts.Format != logger.FormatText && opts.Format != logger.FormatJSON {
+		log.Warnf("Invalid %s %q, using %s", settingLogFormat, opts.Format, logger.FormatText)
+		opts.Format = logger.FormatText
+	}
+
+	return opts
+}
@@ -0,0 +1,138 @@
+package pkg_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
+)
+
+// TestLogOptions : the log settings, an invalid level or format falling back to its default
+func TestLogOptions(t *testing.T) {
+	t.Setenv("SIMPLE_TFSWITCH_CONFIG", filepath.Join(t.TempDir(), "none.hcl"))
+	chdir(t, t.TempDir())
+	for _, env := range []string{"SIMPLE_TFSWITCH_DEBUG", "SIMPLE_TFSWITCH_LOG_LEVEL", "SIMPLE_TFSWITCH_LOG_FORMAT", "SIMPLE_TFSWITCH_LOG_FILE"} {
+		t.Setenv(env, "")
+	}
+
+	if opts := pkg.LogOptions(); opts.Level != log.InfoLevel || opts.Format != logger.FormatText || opts.File != "" {
+		t.Errorf("Expected info text logs on stderr by default, got %+v (unexpected)", opts)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_DEBUG", "1")
+	if opts := pkg.LogOptions(); opts.Level != log.DebugLevel {
+		t.Errorf("Expected SIMPLE_TFSWITCH_DEBUG to turn on the debug level, got %v (unexpected)", opts.Level)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_LOG_LEVEL", "trace")
+	t.Setenv("SIMPLE_TFSWITCH_LOG_FORMAT", "json")
+	t.Setenv("SIMPLE_TFSWITCH_LOG_FILE", "/var/log/simple-tfswitch.log")
+	expected := logger.Options{Level: log.TraceLevel, Format: logger.FormatJSON, File: "/var/log/simple-tfswitch.log"}
+	if opts := pkg.LogOptions(); opts != expected {
+		t.Errorf("Expected %+v, got %+v (unexpected)", expected, opts)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_LOG_LEVEL", "fatal")
+	t.Setenv("SIMPLE_TFSWITCH_LOG_FORMAT", "xml")
+	if opts := pkg.LogOptions(); opts.Level != log.InfoLevel || opts.Format != logger.FormatText {
+		t.Errorf("Expected invalid settings to fall back to info text logs, got %+v (unexpected)", opts)
+	}
+}
+
+// TestLogFields : the JSON logs written to the log file tell which version was installed from which mirror
+func TestLogFields(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.3")
+
+	path := filepath.Join(t.TempDir(), "simple-tfswitch.log")
+	if err := logger.Configure(logger.Options{Level: log.DebugLevel, Format: logger.FormatJSON, File: path}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(logger.Setup)
+
+	if _, err := pkg.Install("1.2.3", srv.source()); err != nil {
+		t.Fatalf("Unable to install: %v (unexpected)", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected JSON lines, got %q: %v (unexpected)", scanner.Text(), err)
+		}
+		if entry[logger.FieldMirror] == nil {
+			continue
+		}
+
+		if entry[logger.FieldVersion] != "1.2.3" || entry[logger.FieldMirror] != srv.source().String() {
+			t.Errorf("Expected the install of 1.2.3 from %s, got %v (unexpected)", srv.source(), entry)
+		}
+		if _, ok := entry[logger.FieldDuration].(float64); !ok {
+			t.Errorf("Expected a duration in seconds, got %v (unexpected)", entry)
+		}
+
+		return
+	}
+	t.Errorf("Expected the install to be logged with its mirror (unexpected)")
+}
+
+// TestLogResolved : the version used for a directory is logged at the debug level with the constraint
+// and where it comes from, when installed and when already installed
+func TestLogResolved(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_VERSION", "")
+	srv := newReleaseServer(t, "1.2.3")
+	_, module := newRepository(t)
+
+	path := filepath.Join(t.TempDir(), "simple-tfswitch.log")
+	if err := logger.Configure(logger.Options{Level: log.DebugLevel, Format: logger.FormatJSON, File: path}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(logger.Setup)
+
+	for i := 0; i < 2; i++ {
+		if _, err := pkg.InstallTFProvidedModule(module, srv.source()); err != nil {
+			t.Fatalf("Unable to install: %v (unexpected)", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	resolved := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected JSON lines, got %q: %v (unexpected)", scanner.Text(), err)
+		}
+		// the requirement found is logged as well, without a version
+		if entry[logger.FieldConstraint] == nil || entry[logger.FieldVersion] == nil {
+			continue
+		}
+
+		resolved++
+		if entry[logger.FieldVersion] != "1.2.3" || entry[logger.FieldConstraint] != "~> 1.2.0" || entry["level"] != "debug" ||
+			entry[logger.FieldSource] != "required_version of "+filepath.Join(module, "main.tf")+" (~> 1.2.0)" {
+			t.Errorf("Expected 1.2.3 resolved from the required_version of the module, got %v (unexpected)", entry)
+		}
+	}
+	if resolved != 2 {
+		t.Errorf("Expected the resolution to be logged for both runs, got %d (unexpected)", resolved)
+	}
+}
@@ -0,0 +1,258 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
+)
+
+const (
+	// DefaultMirror : the hashicorp releases, used when no mirror is configured
+	DefaultMirror = "https://releases.hashicorp.com/terraform"
+
+	mirrorsEnv       = "SIMPLE_TFSWITCH_MIRRORS"
+	mirrorHealthFile = "mirror-health.json"
+	unhealthyAfter   = 3 // consecutive failures
+	unhealthyFor     = 15 * time.Minute
+)
+
+// Mirrors : the mirrors of the current product, see Product.Mirrors
+func Mirrors() []string {
+	return CurrentProduct().Mirrors()
+}
+
+// NewMirrors : a source trying the mirrors of product at locations in order, a single mirror is its own source
+func NewMirrors(locations []string, product *Product) (Source, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("no mirror configured")
+	}
+
+	sources := make([]Source, len(locations))
+	for i, location := range locations {
+		src, err := NewSource(location, product)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = src
+	}
+
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+
+	return &FailoverSource{Sources: sources}, nil
+}
+
+// FailoverSource : mirrors tried in order until one answers, the mirrors which keep failing
+// are tried last for a while. A mirror which does not have a release is not failing,
+// it may only lag behind the others.
+type FailoverSource struct {
+	Sources []Source
+}
+
+func (s *FailoverSource) String() string {
+	locations := make([]string, len(s.Sources))
+	for i, src := range s.Sources {
+		locations[i] = src.String()
+	}
+
+	return strings.Join(locations, ", ")
+}
+
+// Releases : the releases of the first mirror answering
+func (s *FailoverSource) Releases() ([]*Release, error) {
+	var releases []*Release
+	err := s.try("list the versions", func(src Source) (err error) {
+		releases, err = src.Releases()
+
+		return err
+	})
+
+	return releases, err
+}
+
+// Release : the release of the first mirror having it
+func (s *FailoverSource) Release(version string) (*Release, error) {
+	var release *Release
+	err := s.try("describe "+version, func(src Source) (err error) {
+		release, err = src.Release(version)
+
+		return err
+	})
+
+	return release, err
+}
+
+// Open : open url on the mirror it comes from
+func (s *FailoverSource) Open(url string) (io.ReadCloser, error) {
+	for _, src := range s.Sources {
+		if strings.HasPrefix(url, sourceURL(src)) {
+			r, err := src.Open(url)
+			recordMirrorHealth(src, err)
+
+			return r, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s does not come from any of %s", errNotFound, url, s)
+}
+
+// try : run op on each mirror, healthy ones first, until it succeeds
+func (s *FailoverSource) try(what string, op func(src Source) error) error {
+	var err error
+	for _, src := range orderedMirrors(s) {
+		if err = op(src); err == nil {
+			log.WithField(logger.FieldMirror, src.String()).Debugf("Using mirror %s to %s", src, what)
+			recordMirrorHealth(src, nil)
+
+			return nil
+		}
+		log.WithField(logger.FieldMirror, src.String()).Warnf("Unable to %s from mirror %s, trying the next one: %v", what, src, err)
+		recordMirrorHealth(src, err)
+	}
+
+	return err
+}
+
+// mirrorsOf : the mirrors of src to try in order, healthy ones first, only the local ones when offline
+func mirrorsOf(src Source) []Source {
+	mirrors := orderedMirrors(src)
+	if !Offline() {
+		return mirrors
+	}
+
+	local := []Source{}
+	for _, m := range mirrors {
+		if !isRemote(m) {
+			local = append(local, m)
+		}
+	}
+
+	return local
+}
+
+// orderedMirrors : the mirrors of src, the unhealthy ones moved last
+func orderedMirrors(src Source) []Source {
+	failover, ok := src.(*FailoverSource)
+	if !ok {
+		return []Source{src}
+	}
+
+	health := readMirrorHealth()
+	healthy := []Source{}
+	unhealthy := []Source{}
+	for _, m := range failover.Sources {
+		if health[m.String()].unhealthy() {
+			log.Debugf("Mirror %s failed %d times in a row, trying it last", m, health[m.String()].Failures)
+			unhealthy = append(unhealthy, m)
+		} else {
+			healthy = append(healthy, m)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// sourceURL : the url all the artifacts of src start with
+func sourceURL(src Source) string {
+	switch s := src.(type) {
+	case *HTTPSource:
+		return s.URL
+	case *GitHubSource:
+		return s.URL
+	case *FileSource:
+		return s.URL
+	case *DirSource:
+		return withSlash(fileURL(s.Dir))
+	default:
+		return src.String()
+	}
+}
+
+// mirrorHealth : the recent failures of a mirror
+type mirrorHealth struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"` //nolint:tagliatelle // snake case like the other cache files
+}
+
+// unhealthy : the mirror failed too many times in a row, recently
+func (h mirrorHealth) unhealthy() bool {
+	return h.Failures >= unhealthyAfter && time.Since(h.LastFailure) < unhealthyFor
+}
+
+// mirrorHealthPath : where the health of the mirrors is remembered
+func mirrorHealthPath() (string, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(installLocation, cacheDir, mirrorHealthFile), nil
+}
+
+// readMirrorHealth : the health of the mirrors by location, empty when unknown
+func readMirrorHealth() map[string]mirrorHealth {
+	health := map[string]mirrorHealth{}
+
+	path, err := mirrorHealthPath()
+	if err != nil {
+		return health
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return health
+	}
+	if err := json.Unmarshal(content, &health); err != nil {
+		log.Debugf("Ignoring invalid mirror health file %s: %v", path, err)
+	}
+
+	return health
+}
+
+// mirrorHealthMu : serializes the updates of the health file by concurrent installs
+var mirrorHealthMu sync.Mutex //nolint:gochecknoglobals // guards a file shared by the whole process
+
+// recordMirrorHealth : remember the outcome of a request to a mirror, a missing artifact is no failure
+func recordMirrorHealth(src Source, err error) {
+	if errors.Is(err, errNotFound) || errors.Is(err, ErrOffline) {
+		return
+	}
+
+	mirrorHealthMu.Lock()
+	defer mirrorHealthMu.Unlock()
+
+	health := readMirrorHealth()
+	h := health[src.String()]
+	if err == nil {
+		if h.Failures == 0 {
+			return
+		}
+		delete(health, src.String())
+	} else {
+		h.Failures++
+		h.LastFailure = time.Now()
+		health[src.String()] = h
+	}
+
+	path, errPath := mirrorHealthPath()
+	if errPath != nil {
+		return
+	}
+	content, errJSON := json.Marshal(health)
+	if errJSON != nil {
+		return
+	}
+	if errWrite := writeFileAtomic(path, content); errWrite != nil {
+		log.Debugf("Unable to record the health of mirror %s: %v", src, errWrite)
+	}
+}
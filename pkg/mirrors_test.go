@@ -0,0 +1,87 @@
+package pkg_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestMirrors : the mirrors are listed in order, the hashicorp releases by default
+func TestMirrors(t *testing.T) {
+	t.Setenv("SIMPLE_TFSWITCH_MIRRORS", "")
+	if mirrors := pkg.Mirrors(); !reflect.DeepEqual(mirrors, []string{pkg.DefaultMirror}) {
+		t.Errorf("Expected the default mirror, got %v (unexpected)", mirrors)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_MIRRORS", "https://mirror.example.com/terraform, file:///srv/terraform /srv/zips")
+	expected := []string{"https://mirror.example.com/terraform", "file:///srv/terraform", "/srv/zips"}
+	if mirrors := pkg.Mirrors(); !reflect.DeepEqual(mirrors, expected) {
+		t.Errorf("Expected mirrors %v, got %v (unexpected)", expected, mirrors)
+	}
+}
+
+// brokenMirror : a mirror failing every request, counting them,
+// with a status the http client does not retry to keep the tests fast
+func brokenMirror(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	requests := new(int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		http.Error(w, "broken", http.StatusNotImplemented)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, requests
+}
+
+// TestInstallMirrorFailover : a broken or lagging mirror is skipped for the next one
+func TestInstallMirrorFailover(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	broken, _ := brokenMirror(t)
+	lagging := newReleaseServer(t, "1.2.0")
+	srv := newReleaseServer(t, "1.2.0", "1.2.3")
+
+	src, err := pkg.NewMirrors([]string{broken.URL + "/terraform", lagging.URL + "/terraform", srv.URL + "/terraform"}, pkg.Terraform())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := pkg.GetTFList(src, false)
+	if expected := []string{"1.2.0"}; err != nil || !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected the versions %v of the first mirror answering, got %v, %v (unexpected)", expected, versions, err)
+	}
+
+	if _, err := pkg.Install("1.2.3", src); err != nil {
+		t.Errorf("Unable to install 1.2.3 from the last mirror: %v (unexpected)", err)
+	}
+}
+
+// TestInstallUnhealthyMirrorLast : a mirror failing repeatedly is tried after the others
+func TestInstallUnhealthyMirrorLast(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	broken, requests := brokenMirror(t)
+	srv := newReleaseServer(t, "1.2.3")
+
+	src, err := pkg.NewMirrors([]string{broken.URL + "/terraform", srv.URL + "/terraform"}, pkg.Terraform())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := pkg.Install("1.2.3", src); err != nil {
+			t.Fatalf("Unable to install 1.2.3: %v (unexpected)", err)
+		}
+		if _, err := pkg.Uninstall("1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Errorf("Expected the broken mirror to be skipped after 3 failures, got %d requests (unexpected)", n)
+	}
+}
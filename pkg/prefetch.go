@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPrefetchJobs : how many versions Prefetch installs at a time by default
+const DefaultPrefetchJobs = 4
+
+// PrefetchResult : a version installed by Prefetch and what required it,
+// or a constraint which could not be resolved or installed, with the error
+type PrefetchResult struct {
+	Product    string   `json:"product"`
+	Version    string   `json:"version,omitempty"`
+	Constraint string   `json:"constraint,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Dirs       []string `json:"dirs,omitempty"`
+	Error      string   `json:"error,omitempty"`
+
+	err error
+}
+
+// prefetchKey : a constraint of a product
+type prefetchKey struct {
+	product    string
+	constraint string
+}
+
+// Prefetch : install every version required by the directories under roots and the versions or constraints given,
+// each version once, at most jobs at a time. The versions given are of the product of src, the directories
+// using another product get their versions from the mirrors of their product.
+// A failure does not stop the other installs, all the results are returned along with an error when any failed.
+func Prefetch(roots []string, versions []string, jobs int, src Source) ([]PrefetchResult, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// directories requiring each constraint, "" for the versions given
+	product := productOf(src)
+	sources := map[string]Source{product.Name: src}
+	constraints := map[prefetchKey][]string{}
+	// the lock of each locked version, its download checked against the hash of the lock
+	locks := map[prefetchKey]*LockedVersion{}
+	for _, v := range versions {
+		key := prefetchKey{product.Name, v}
+		constraints[key] = append(constraints[key], "")
+	}
+	for _, root := range roots {
+		requirements, err := ScanRequirements(root)
+		if err != nil {
+			return nil, opError(ErrFilesystem, "unable to scan "+root, err)
+		}
+		for _, dir := range SortedDirs(requirements) {
+			p := ProductFor(dir)
+			if sources[p.Name] == nil {
+				if sources[p.Name], err = NewMirrors(p.Mirrors(), p); err != nil {
+					return nil, err
+				}
+			}
+			key := prefetchKey{p.Name, requirements[dir].Constraint}
+			constraints[key] = append(constraints[key], dir)
+			if lock := requirements[dir].Lock; lock != nil {
+				locks[prefetchKey{p.Name, lock.Version}] = lock
+			}
+		}
+	}
+
+	results, byVersion := resolvePrefetch(constraints, sources)
+
+	todo := make(chan *PrefetchResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range todo {
+				log.Debugf("Prefetching %s %s", r.Product, r.Version)
+				r.Path, r.err = install(r.Version, sources[r.Product], locks[prefetchKey{r.Product, r.Version}])
+				if r.err != nil {
+					r.Error = r.err.Error()
+				}
+			}
+		}()
+	}
+	for _, r := range byVersion {
+		todo <- r
+	}
+	close(todo)
+	wg.Wait()
+
+	for _, r := range byVersion {
+		results = append(results, *r)
+	}
+	sortPrefetchResults(results)
+
+	var firstErr error
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d versions could not be prefetched, first: %w", failed, len(results), firstErr)
+	}
+
+	return results, nil
+}
+
+// resolvePrefetch : the version of each constraint with the source of its product, the directories of the constraints
+// resolving to the same version merged, and the results of the constraints which could not be resolved
+func resolvePrefetch(constraints map[prefetchKey][]string, sources map[string]Source) ([]PrefetchResult, map[prefetchKey]*PrefetchResult) {
+	failed := []PrefetchResult{}
+	byVersion := map[prefetchKey]*PrefetchResult{}
+	for key, dirs := range constraints {
+		tfversion, err := ResolveVersion(key.constraint, sources[key.product])
+		if err != nil {
+			log.Warnf("Unable to resolve %s %q required by %v: %v", key.product, key.constraint, dirs, err)
+			failed = append(failed, PrefetchResult{
+				Product: key.product, Constraint: key.constraint, Dirs: withoutEmpty(dirs), Error: err.Error(), err: err,
+			})
+
+			continue
+		}
+
+		r, ok := byVersion[prefetchKey{key.product, tfversion}]
+		if !ok {
+			r = &PrefetchResult{Product: key.product, Version: tfversion}
+			byVersion[prefetchKey{key.product, tfversion}] = r
+		}
+		r.Dirs = append(r.Dirs, withoutEmpty(dirs)...)
+	}
+
+	for _, r := range byVersion {
+		sort.Strings(r.Dirs)
+	}
+
+	return failed, byVersion
+}
+
+// sortPrefetchResults : by product, the versions from the newest, then the constraints which could not be resolved
+func sortPrefetchResults(results []PrefetchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		vi, erri := semver.NewVersion(results[i].Version)
+		vj, errj := semver.NewVersion(results[j].Version)
+		switch {
+		case results[i].Product != results[j].Product:
+			return results[i].Product < results[j].Product
+		case erri != nil && errj != nil:
+			return results[i].Constraint < results[j].Constraint
+		case erri != nil || errj != nil:
+			return errj != nil
+		default:
+			return vi.GreaterThan(vj)
+		}
+	})
+}
+
+// withoutEmpty : values without the empty ones
+func withoutEmpty(values []string) []string {
+	kept := []string{}
+	for _, v := range values {
+		if v != "" {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,52 @@
+package pkg_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestPrefetch : every version required under a tree and given is installed once, a failure does not stop the others
+func TestPrefetch(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.2.0", "1.2.3", "1.3.0")
+
+	repo, module := newRepository(t)
+	for dir, content := range map[string]string{
+		"envs/dev":     "terraform {\n  required_version = \"~> 1.2.0\"\n}\n",
+		"modules/vpc":  "terraform {\n  required_version = \">= 1.3\"\n}\n",
+		"modules/next": "terraform {\n  required_version = \"~> 9.0\"\n}\n",
+	} {
+		createDirIfNotExist(filepath.Join(repo, dir))
+		writeFile(t, filepath.Join(repo, dir, "main.tf"), content)
+	}
+	writeFile(t, filepath.Join(module, ".terraform-version"), "1.2.3\n")
+
+	results, err := pkg.Prefetch([]string{repo}, []string{"1.2.0"}, 2, srv.source())
+	if err == nil {
+		t.Errorf("Expected an error for the constraint matching no version (unexpected)")
+	}
+
+	expected := []pkg.PrefetchResult{
+		{Version: "1.3.0", Dirs: []string{filepath.Join(repo, "modules/vpc")}},
+		{Version: "1.2.3", Dirs: []string{filepath.Join(repo, "envs/dev"), module}},
+		{Version: "1.2.0"},
+		{Constraint: "~> 9.0", Dirs: []string{filepath.Join(repo, "modules/next")}},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v (unexpected)", len(expected), results)
+	}
+	for i, r := range results {
+		if r.Version != expected[i].Version || r.Constraint != expected[i].Constraint || !reflect.DeepEqual(r.Dirs, expected[i].Dirs) {
+			t.Errorf("Expected %+v, got %+v (unexpected)", expected[i], r)
+		}
+		if (r.Error == "") != (r.Version != "") {
+			t.Errorf("Expected only the unresolved constraint to fail, got %+v (unexpected)", r)
+		}
+		if r.Version != "" && (r.Path == "" || !checkFileExist(r.Path)) {
+			t.Errorf("Expected %s to be installed, got %q (unexpected)", r.Version, r.Path)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OpenTofuMirror : the OpenTofu releases on GitHub, listed by the OpenTofu versions index,
+	// used when no tofu mirror is configured
+	OpenTofuMirror = "https://github.com/opentofu/opentofu/releases/download/?index=https://get.opentofu.org/tofu/api.json"
+
+	productEnv = "SIMPLE_TFSWITCH_PRODUCT"
+)
+
+// Product : a tool installed and run by simple-tfswitch, its name is also the name of its binary
+// and prefixes its release files and its installed versions
+type Product struct {
+	Name  string
+	Title string
+	// VersionFile : the file pinning the version of the product, looked for up to the repository root
+	VersionFile string
+	// ToolVersionsPlugin : the asdf/mise plugin of the product in .tool-versions files
+	ToolVersionsPlugin string
+	// Extensions : the extensions of the configuration files, a file of a later extension
+	// replacing the file of the same name with an earlier one
+	Extensions []string
+	// SignatureExt : the extension of the detached signature of the SHA256SUMS files
+	SignatureExt string
+
+	mirrorsSetting string
+	defaultMirror  string
+	keyringSetting string
+	// signingKey : the armored release signing key embedded for the product, "" when none
+	signingKey string
+	// signingKeyURL : where the release signing key of the product is published, to trust it when it is not embedded
+	signingKeyURL string
+}
+
+// Terraform : HashiCorp Terraform, the default product
+func Terraform() *Product {
+	return &Product{
+		Name:               "terraform",
+		Title:              "Terraform",
+		VersionFile:        ".terraform-version",
+		ToolVersionsPlugin: "terraform",
+		Extensions:         []string{".tf", ".tf.json"},
+		SignatureExt:       ".sig",
+		mirrorsSetting:     settingMirrors,
+		defaultMirror:      DefaultMirror,
+		keyringSetting:     settingKeyring,
+		signingKey:         hashicorpPublicKey,
+	}
+}
+
+// OpenTofu : the OpenTofu fork of Terraform, reading the terraform files as well as its own .tofu files
+func OpenTofu() *Product {
+	return &Product{
+		Name:               "tofu",
+		Title:              "OpenTofu",
+		VersionFile:        ".opentofu-version",
+		ToolVersionsPlugin: "opentofu",
+		Extensions:         []string{".tf", ".tf.json", ".tofu", ".tofu.json"},
+		SignatureExt:       ".gpgsig",
+		mirrorsSetting:     settingTofuMirrors,
+		defaultMirror:      OpenTofuMirror,
+		keyringSetting:     settingTofuKeyring,
+		signingKeyURL:      "https://get.opentofu.org/opentofu.asc",
+	}
+}
+
+// Products : every product, the default one first
+func Products() []*Product {
+	return []*Product{Terraform(), OpenTofu()}
+}
+
+// GetProduct : the product of a name, either terraform, or tofu or opentofu
+func GetProduct(name string) (*Product, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range Products() {
+		if name == p.Name || name == p.ToolVersionsPlugin {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: unknown product %q, terraform or tofu", ErrConfig, name)
+}
+
+// ProductInvokedAs : the product the binary pretends to be when invoked through a symlink as argv0,
+// nil when invoked under its own name
+func ProductInvokedAs(argv0 string) *Product {
+	name := strings.TrimSuffix(filepath.Base(argv0), ".exe")
+	for _, p := range Products() {
+		if name == p.Name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// PassThroughProduct : the product to run in dir when invoked as argv0, nil when invoked as simple-tfswitch.
+// Invoked as tofu, OpenTofu is always run, invoked as terraform, dir chooses.
+func PassThroughProduct(argv0 string, dir string) *Product {
+	p := ProductInvokedAs(argv0)
+	if p == nil || p.Name != Terraform().Name {
+		return p
+	}
+
+	return ProductFor(dir)
+}
+
+// UseProduct : make p the product of the whole process, and of the processes it starts,
+// as if set by SIMPLE_TFSWITCH_PRODUCT
+func UseProduct(p *Product) error {
+	return os.Setenv(productEnv, p.Name)
+}
+
+// CurrentProduct : the product used in the working directory, see ProductFor
+func CurrentProduct() *Product {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+
+	return ProductFor(dir)
+}
+
+// ProductFor : the product used in dir, by order of precedence:
+// - the SIMPLE_TFSWITCH_PRODUCT environment variable
+// - the closest version file from dir up to the repository root, .opentofu-version winning over .terraform-version,
+// then the first .tool-versions line of opentofu or terraform
+// - .tofu files in dir
+// - the product setting of the configuration files
+// - terraform
+func ProductFor(dir string) *Product {
+	setting := configSetting(settingProduct)
+	if strings.HasPrefix(setting.Origin, originEnv) {
+		return settingProductValue(setting)
+	}
+
+	if p := versionFileProduct(dir); p != nil {
+		return p
+	}
+
+	if hasTofuFiles(dir) {
+		log.Debugf("Using %s for the .tofu files of %s", OpenTofu().Title, dir)
+
+		return OpenTofu()
+	}
+
+	return settingProductValue(setting)
+}
+
+// hasTofuFiles : dir holds OpenTofu specific configuration files
+func hasTofuFiles(dir string) bool {
+	for _, pattern := range []string{"*.tofu", "*.tofu.json"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// settingProductValue : the product of the product setting, terraform when invalid
+func settingProductValue(setting Setting) *Product {
+	if setting.Value == "" {
+		return Terraform()
+	}
+
+	p, err := GetProduct(setting.Value)
+	if err != nil {
+		log.Warnf("Invalid %s from %s, using terraform: %v", settingProduct, setting.Origin, err)
+
+		return Terraform()
+	}
+
+	return p
+}
+
+// versionFileProduct : the product of the closest version file from dir up to the repository root, nil if none
+func versionFileProduct(dir string) *Product {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	for {
+		// a stack migrated to OpenTofu may keep its .terraform-version for older tools
+		for _, p := range []*Product{OpenTofu(), Terraform()} {
+			if CheckFileExist(filepath.Join(dir, p.VersionFile)) {
+				return p
+			}
+		}
+
+		lines, _ := readVersionFileLines(filepath.Join(dir, toolVersions))
+		for _, line := range lines {
+			if p, err := GetProduct(strings.Fields(line)[0]); err == nil {
+				return p
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if isRepositoryRoot(dir) || parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Mirrors : the locations of the mirrors of the product to try in order, see NewSource for the kinds of mirrors,
+// set by the mirrors setting or SIMPLE_TFSWITCH_MIRRORS for terraform, the hashicorp releases by default,
+// and by the tofu_mirrors setting or SIMPLE_TFSWITCH_TOFU_MIRRORS for tofu, the GitHub releases by default,
+// as a comma or space separated list
+func (p *Product) Mirrors() []string {
+	mirrors := configList(p.mirrorsSetting)
+	if len(mirrors) == 0 {
+		return []string{p.defaultMirror}
+	}
+
+	return mirrors
+}
+
+// installedName : the file name of an installed version, without the executable extension
+func (p *Product) installedName(tfversion string) string {
+	return p.Name + "_" + tfversion
+}
+
+// productOf : the product whose releases src serves, terraform unless set otherwise
+func productOf(src Source) *Product {
+	var p *Product
+	switch s := src.(type) {
+	case *HTTPSource:
+		p = s.Product
+	case *GitHubSource:
+		p = s.Product
+	case *FileSource:
+		p = s.Product
+	case *DirSource:
+		p = s.Product
+	case *FailoverSource:
+		if len(s.Sources) > 0 {
+			return productOf(s.Sources[0])
+		}
+	}
+
+	if p == nil {
+		return Terraform()
+	}
+
+	return p
+}
@@ -0,0 +1,125 @@
+package pkg_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestProductFor : the closest version file chooses the product, then the .tofu files, the environment wins over both
+func TestProductFor(t *testing.T) {
+	repo, module := newRepository(t)
+
+	expectProduct := func(dir string, expected string) {
+		t.Helper()
+
+		if p := pkg.ProductFor(dir); p.Name != expected {
+			t.Errorf("Expected %s in %s, got %s (unexpected)", expected, dir, p.Name)
+		}
+	}
+
+	expectProduct(module, "terraform")
+
+	writeFile(t, filepath.Join(module, "versions.tofu"), "terraform {\n  required_version = \"~> 1.6.0\"\n}\n")
+	expectProduct(module, "tofu")
+
+	writeFile(t, filepath.Join(repo, ".terraform-version"), "1.5.7\n")
+	expectProduct(module, "terraform")
+
+	writeFile(t, filepath.Join(repo, "envs", ".tool-versions"), "nodejs 20.0.0\nopentofu 1.6.2\n")
+	expectProduct(module, "tofu")
+
+	writeFile(t, filepath.Join(module, ".terraform-version"), "1.5.7\n")
+	expectProduct(module, "terraform")
+
+	writeFile(t, filepath.Join(module, ".opentofu-version"), "1.6.2\n")
+	expectProduct(module, "tofu")
+
+	t.Setenv("SIMPLE_TFSWITCH_PRODUCT", "terraform")
+	expectProduct(module, "terraform")
+}
+
+// TestPassThroughProduct : invoked as tofu OpenTofu always runs, invoked as terraform the directory chooses
+func TestPassThroughProduct(t *testing.T) {
+	_, module := newRepository(t)
+	writeFile(t, filepath.Join(module, ".opentofu-version"), "1.6.2\n")
+	plain := t.TempDir()
+
+	for _, tt := range []struct {
+		argv0    string
+		dir      string
+		expected string
+	}{
+		{"/usr/local/bin/terraform", module, "tofu"},
+		{"/usr/local/bin/terraform", plain, "terraform"},
+		{"/usr/local/bin/tofu", plain, "tofu"},
+		{"/usr/local/bin/simple-tfswitch", module, ""},
+	} {
+		name := ""
+		if p := pkg.PassThroughProduct(tt.argv0, tt.dir); p != nil {
+			name = p.Name
+		}
+		if name != tt.expected {
+			t.Errorf("Expected %q for %s in %s, got %q (unexpected)", tt.expected, tt.argv0, tt.dir, name)
+		}
+	}
+}
+
+// TestInstallOpenTofu : OpenTofu is installed from the GitHub releases layout with its armored signatures,
+// for the required_version of the .tofu files which replace the .tf files of the same name
+func TestInstallOpenTofu(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newTofuServer(t, "1.5.7", "1.6.2")
+
+	_, module := newRepository(t)
+	writeFile(t, filepath.Join(module, "main.tf"), "terraform {\n  required_version = \"~> 1.5.0\"\n}\n")
+	writeFile(t, filepath.Join(module, "main.tofu"), "terraform {\n  required_version = \"~> 1.6.0\"\n}\n")
+
+	src, err := pkg.NewSource(srv.location(), pkg.OpenTofu())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	installed, err := pkg.InstallTFProvidedModule(module, src)
+	if err != nil {
+		t.Fatalf("Unable to install OpenTofu: %v (unexpected)", err)
+	}
+	if filepath.Base(installed) != pkg.ConvertExecutableExt("tofu_1.6.2") {
+		t.Errorf("Expected tofu 1.6.2 to be installed, got %s (unexpected)", installed)
+	}
+
+	// installed versions are listed per product
+	if local, err := pkg.GetLocalTFList(); err != nil || len(local) != 0 {
+		t.Errorf("Expected no terraform version installed, got %v, %v (unexpected)", local, err)
+	}
+	t.Setenv("SIMPLE_TFSWITCH_PRODUCT", "tofu")
+	if local, err := pkg.GetLocalTFList(); err != nil || !reflect.DeepEqual(local, []string{"1.6.2"}) {
+		t.Errorf("Expected tofu 1.6.2 installed, got %v, %v (unexpected)", local, err)
+	}
+}
+
+// TestInstallOpenTofuDefaultKeyring : without any key trusted for OpenTofu, its releases are refused
+// and the error tells where its signing key is published
+func TestInstallOpenTofuDefaultKeyring(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newTofuServer(t, "1.6.2")
+	keyring := filepath.Join(t.TempDir(), "trusted-tofu-keys.asc")
+	t.Setenv("SIMPLE_TFSWITCH_TOFU_KEYRING", keyring)
+
+	src, err := pkg.NewSource(srv.location(), pkg.OpenTofu())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pkg.Install("1.6.2", src)
+	if !errors.Is(err, pkg.ErrChecksum) {
+		t.Fatalf("Expected OpenTofu to be refused without a trusted key, got %v (unexpected)", err)
+	}
+	if !strings.Contains(err.Error(), "https://get.opentofu.org/opentofu.asc") || !strings.Contains(err.Error(), keyring) {
+		t.Errorf("Expected the error to tell where the key is published and where to add it, got %v (unexpected)", err)
+	}
+}
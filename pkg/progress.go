@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ProgressAuto : a progress bar when stderr is a terminal, log lines otherwise
+	ProgressAuto = "auto"
+	// ProgressBar : a progress bar redrawn on stderr
+	ProgressBar = "bar"
+	// ProgressLog : a log line from time to time, for long downloads only
+	ProgressLog = "log"
+	// ProgressOff : no progress at all
+	ProgressOff = "off"
+
+	progressRedraw   = 200 * time.Millisecond
+	progressLogEvery = 10 * time.Second
+)
+
+// activeBars : the progress bars being drawn, concurrent downloads log their progress instead
+var activeBars int32 //nolint:gochecknoglobals // shared by the concurrent downloads
+
+// ProgressMode : how downloads report their progress, set by the progress setting or SIMPLE_TFSWITCH_PROGRESS,
+// one of bar or log, or off, auto chooses a bar when stderr is a terminal
+func ProgressMode() string {
+	mode := strings.ToLower(strings.TrimSpace(configString(settingProgress)))
+	switch mode {
+	case ProgressBar, ProgressLog, ProgressOff:
+		return mode
+	case ProgressAuto:
+	default:
+		log.Warnf("Invalid %s %q, using %s", settingProgress, mode, ProgressAuto)
+	}
+
+	if isTerminal(os.Stderr) {
+		return ProgressBar
+	}
+
+	return ProgressLog
+}
+
+// isTerminal : f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// FormatProgress : done out of total bytes, with the rate and the remaining time after elapsed
+// since the download started or resumed at resumed bytes, as in "12.0MiB / 80.0MiB 15% 2.0MiB/s ETA 34s",
+// total is unknown when not positive
+func FormatProgress(done int64, total int64, resumed int64, elapsed time.Duration) string {
+	rate := int64(0)
+	if elapsed > 0 {
+		rate = int64(float64(done-resumed) / elapsed.Seconds())
+	}
+
+	if total <= 0 {
+		return fmt.Sprintf("%s %s/s", FormatSize(done), FormatSize(rate))
+	}
+
+	eta := "?"
+	if rate > 0 {
+		eta = time.Duration(float64(total-done) / float64(rate) * float64(time.Second)).Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%s / %s %d%% %s/s ETA %s", FormatSize(done), FormatSize(total), done*100/total, FormatSize(rate), eta)
+}
+
+// progress : reports the progress of a download as it is written to, on stderr only
+// as stdout belongs to terraform
+type progress struct {
+	name    string
+	mode    string
+	total   int64
+	resumed int64
+	done    int64
+	start   time.Time
+	last    time.Time
+	out     io.Writer
+}
+
+// newProgress : the progress of the download of name, of total bytes or -1 when unknown, resumed after resumed bytes
+func newProgress(name string, total int64, resumed int64) *progress {
+	mode := ProgressMode()
+	if mode == ProgressBar && atomic.AddInt32(&activeBars, 1) > 1 {
+		atomic.AddInt32(&activeBars, -1)
+		mode = ProgressLog
+	}
+
+	now := time.Now()
+
+	return &progress{
+		name: name, mode: mode, total: total, resumed: resumed, done: resumed, start: now, last: now, out: os.Stderr,
+	}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+
+	now := time.Now()
+	switch p.mode {
+	case ProgressBar:
+		if now.Sub(p.last) >= progressRedraw {
+			p.last = now
+			p.draw("")
+		}
+	case ProgressLog:
+		if now.Sub(p.last) >= progressLogEvery {
+			p.last = now
+			log.Infof("Downloading %s: %s", p.name, FormatProgress(p.done, p.total, p.resumed, now.Sub(p.start)))
+		}
+	}
+
+	return len(b), nil
+}
+
+// finish : the final state of the bar, ending its line
+func (p *progress) finish() {
+	if p.mode != ProgressBar {
+		return
+	}
+	if p.done > 0 {
+		p.draw("\n")
+	}
+	atomic.AddInt32(&activeBars, -1)
+}
+
+// draw : redraw the line of the bar
+func (p *progress) draw(end string) {
+	fmt.Fprintf(p.out, "\r\033[K%s %s%s", p.name, FormatProgress(p.done, p.total, p.resumed, time.Since(p.start)), end)
+}
@@ -0,0 +1,80 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestFormatProgress : sizes, percent, rate and remaining time of a download
+func TestFormatProgress(t *testing.T) {
+	for _, test := range []struct {
+		done, total, resumed int64
+		elapsed              time.Duration
+		expected             string
+	}{
+		{12 << 20, 80 << 20, 0, 6 * time.Second, "12.0MiB / 80.0MiB 15% 2.0MiB/s ETA 34s"},
+		{40 << 20, 80 << 20, 30 << 20, 5 * time.Second, "40.0MiB / 80.0MiB 50% 2.0MiB/s ETA 20s"},
+		{512, 2048, 0, 0, "512B / 2.0KiB 25% 0B/s ETA ?"},
+		{3 << 30, -1, 0, 3 * time.Second, "3.0GiB 1.0GiB/s"},
+	} {
+		if progress := pkg.FormatProgress(test.done, test.total, test.resumed, test.elapsed); progress != test.expected {
+			t.Errorf("Expected %q, got %q (unexpected)", test.expected, progress)
+		}
+	}
+}
+
+// TestInstallProgressOnStderr : the progress bar is written to stderr, never to stdout
+func TestInstallProgressOnStderr(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_PROGRESS", "bar")
+	srv := newReleaseServer(t, "1.2.3")
+
+	stdout := redirect(t, &os.Stdout)
+	stderr := redirect(t, &os.Stderr)
+
+	if _, err := pkg.Install("1.2.3", srv.source()); err != nil {
+		t.Fatalf("Unable to install 1.2.3: %v (unexpected)", err)
+	}
+
+	if out := readRedirected(t, stdout); out != "" {
+		t.Errorf("Expected nothing on stdout, got %q (unexpected)", out)
+	}
+	if out := readRedirected(t, stderr); !strings.Contains(out, "terraform_1.2.3_") || !strings.Contains(out, "100%") {
+		t.Errorf("Expected the progress of the download on stderr, got %q (unexpected)", out)
+	}
+}
+
+// redirect : point *f to a temporary file for the duration of the test
+func redirect(t *testing.T, f **os.File) string {
+	t.Helper()
+
+	tmp, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := *f
+	*f = tmp
+	t.Cleanup(func() {
+		*f = previous
+		tmp.Close()
+	})
+
+	return tmp.Name()
+}
+
+// readRedirected : what was written to a redirected output
+func readRedirected(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
@@ -0,0 +1,419 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pruneKeepEnv     = "SIMPLE_TFSWITCH_PRUNE_KEEP"
+	pruneKeepDaysEnv = "SIMPLE_TFSWITCH_PRUNE_KEEP_DAYS"
+	pruneReposEnv    = "SIMPLE_TFSWITCH_PRUNE_REPOS"
+	pruneMaxSizeEnv  = "SIMPLE_TFSWITCH_PRUNE_MAX_SIZE"
+	autoPruneEnv     = "SIMPLE_TFSWITCH_AUTO_PRUNE"
+	autoPruneStamp   = "last-prune"
+	autoPruneEvery   = 24 * time.Hour
+	day              = 24 * time.Hour
+)
+
+// PrunePolicy : which installed versions to keep, a version is kept when any of the keep rules matches it,
+// when no keep rule is set every version is a candidate for removal
+type PrunePolicy struct {
+	// KeepNewest : keep the N newest versions
+	KeepNewest int
+	// KeepUsedWithin : keep the versions used recently
+	KeepUsedWithin time.Duration
+	// KeepReferencedBy : keep the versions resolved for the configurations found in these directories
+	KeepReferencedBy []string
+	// MaxSize : once the other rules applied, remove the least recently used versions until the total size fits,
+	// a hard cap which removes kept versions as well, the protected ones excepted
+	MaxSize int64
+	// Protect : the installed binaries never removed, by path
+	Protect []string
+	// DryRun : only report what would be removed
+	DryRun bool
+}
+
+// InstalledVersion : an installed version of a product, its last use is the modification time of the binary
+type InstalledVersion struct {
+	Product  string    `json:"product"`
+	Version  string    `json:"version"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"` //nolint:tagliatelle // snake case like the other JSON outputs
+}
+
+// hasKeepRule : the policy keeps some versions on its own
+func (p PrunePolicy) hasKeepRule() bool {
+	return p.KeepNewest > 0 || p.KeepUsedWithin > 0 || len(p.KeepReferencedBy) > 0
+}
+
+// validate : refuse a policy which would remove every installed version
+func (p PrunePolicy) validate() error {
+	if !p.hasKeepRule() && p.MaxSize <= 0 {
+		return errors.New("refusing to prune without any keep rule or maximum size")
+	}
+
+	return nil
+}
+
+// PrunePolicyFromConfig : the prune policy set by the prune_keep, prune_keep_days, prune_repos and prune_max_size
+// settings or their SIMPLE_TFSWITCH_PRUNE_* environment variables
+func PrunePolicyFromConfig() (PrunePolicy, error) {
+	policy := PrunePolicy{}
+
+	keep, err := configPruneInt(settingPruneKeep)
+	if err != nil {
+		return policy, err
+	}
+	policy.KeepNewest = keep
+
+	days, err := configPruneInt(settingPruneKeepDays)
+	if err != nil {
+		return policy, err
+	}
+	policy.KeepUsedWithin = time.Duration(days) * day
+
+	// a path list in the environment, a list in a file
+	for _, repos := range configList(settingPruneRepos) {
+		policy.KeepReferencedBy = append(policy.KeepReferencedBy, filepath.SplitList(repos)...)
+	}
+
+	s := configSetting(settingPruneMaxSize)
+	size, err := ParseSize(s.Value)
+	if err != nil {
+		return policy, fmt.Errorf("%w: invalid %s from %s: %v", ErrConfig, s.Name, s.Origin, err)
+	}
+	policy.MaxSize = size
+
+	return policy, nil
+}
+
+// configPruneInt : the prune setting name as a number, an invalid one being an ErrConfig
+func configPruneInt(name string) (int, error) {
+	s := configSetting(name)
+	value, err := strconv.Atoi(s.Value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid %s %q from %s", ErrConfig, s.Name, s.Value, s.Origin)
+	}
+
+	return value, nil
+}
+
+// ParseSize : parse a size in bytes with an optional K, M, G or T binary unit, as in 500M or 2GiB
+func ParseSize(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+
+	size, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	return int64(size * float64(multiplier)), nil
+}
+
+// FormatSize : a size in bytes with a binary unit, as in 80.1MiB, which ParseSize reads back
+func FormatSize(size int64) string {
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len("KMGT") {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	return fmt.Sprintf("%.1f%ciB", value, "KMGT"[unit-1])
+}
+
+// GetInstalledVersions : the installed versions of every product, by product, newest first
+func GetInstalledVersions() ([]InstalledVersion, error) {
+	installed := []InstalledVersion{}
+	for _, product := range Products() {
+		versions, err := localVersions(product)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range versions {
+			path, err := installedPath(product, v)
+			if err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			installed = append(installed, InstalledVersion{
+				Product: product.Name, Version: v, Path: path, Size: info.Size(), LastUsed: info.ModTime(),
+			})
+		}
+	}
+
+	return installed, nil
+}
+
+// markUsed : record the use of an installed binary for the prune policies
+func markUsed(path string) {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Debugf("Unable to record use of %s: %v", path, err)
+	}
+}
+
+// Prune : remove the installed versions of every product not kept by the policy, return the removed ones.
+// The lock of each removed version is held, and a version used since it was listed is kept,
+// so a binary is never removed while another process installs or selects it.
+func Prune(policy PrunePolicy) ([]InstalledVersion, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	installed, err := GetInstalledVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	keep, err := keptVersions(policy, installed)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := []InstalledVersion{}
+	removed := []InstalledVersion{}
+	for _, v := range installed {
+		// with only a maximum size, every version is kept unless too big
+		if keep[v.Path] || !policy.hasKeepRule() {
+			remaining = append(remaining, v)
+		} else {
+			removed = append(removed, v)
+		}
+	}
+
+	removed = append(removed, overSize(policy, remaining)...)
+
+	if policy.DryRun {
+		for _, v := range removed {
+			log.Infof("Would remove %s %s (%s)", v.Product, v.Version, v.Path)
+		}
+
+		return removed, nil
+	}
+
+	done := make([]InstalledVersion, 0, len(removed))
+	for _, v := range removed {
+		ok, err := removeUnused(v)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			done = append(done, v)
+		}
+	}
+
+	return done, nil
+}
+
+// removeUnused : remove an installed version under its lock, unless it was used since it was listed
+func removeUnused(v InstalledVersion) (bool, error) {
+	product, err := GetProduct(v.Product)
+	if err != nil {
+		return false, err
+	}
+	unlock, err := lockVersion(product, v.Version)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	info, err := os.Stat(v.Path)
+	if err != nil {
+		return false, nil //nolint:nilerr // already removed by another process
+	}
+	if !info.ModTime().Equal(v.LastUsed) {
+		log.Infof("Keeping %s %s, used while pruning", v.Product, v.Version)
+
+		return false, nil
+	}
+
+	if err := os.Remove(v.Path); err != nil {
+		return false, opError(ErrFilesystem, "unable to remove "+v.Product+" "+v.Version, err)
+	}
+	log.Infof("Removed %s %s (%s)", v.Product, v.Version, v.Path)
+
+	return true, nil
+}
+
+// keptVersions : the paths of the versions kept by the keep rules and of the protected versions,
+// the newest versions being kept for each product
+func keptVersions(policy PrunePolicy, installed []InstalledVersion) (map[string]bool, error) {
+	keep := map[string]bool{}
+	for _, path := range policy.Protect {
+		keep[path] = true
+	}
+
+	newest := map[string]int{}
+	for _, v := range installed {
+		if newest[v.Product] < policy.KeepNewest {
+			keep[v.Path] = true
+		}
+		newest[v.Product]++
+		if policy.KeepUsedWithin > 0 && time.Since(v.LastUsed) <= policy.KeepUsedWithin {
+			keep[v.Path] = true
+		}
+	}
+
+	for _, repo := range policy.KeepReferencedBy {
+		referenced, err := referencedVersions(repo, installed)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range referenced {
+			keep[path] = true
+		}
+	}
+
+	return keep, nil
+}
+
+// overSize : the least recently used versions to remove for the remaining ones to fit in the maximum size.
+// The size is a hard cap: versions kept by the keep rules are removed as well, protected versions excepted.
+func overSize(policy PrunePolicy, remaining []InstalledVersion) []InstalledVersion {
+	if policy.MaxSize <= 0 {
+		return nil
+	}
+
+	total := int64(0)
+	for _, v := range remaining {
+		total += v.Size
+	}
+
+	lru := append([]InstalledVersion{}, remaining...)
+	sort.SliceStable(lru, func(i, j int) bool { return lru[i].LastUsed.Before(lru[j].LastUsed) })
+
+	protected := map[string]bool{}
+	for _, path := range policy.Protect {
+		protected[path] = true
+	}
+
+	removed := []InstalledVersion{}
+	for _, v := range lru {
+		if total <= policy.MaxSize {
+			break
+		}
+		if protected[v.Path] {
+			continue
+		}
+		removed = append(removed, v)
+		total -= v.Size
+	}
+
+	return removed
+}
+
+// referencedVersions : the paths of the installed versions the configurations of repo resolve to,
+// each with the product of its directory
+func referencedVersions(repo string, installed []InstalledVersion) ([]string, error) {
+	requirements, err := ScanRequirements(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := []string{}
+	for _, dir := range SortedDirs(requirements) {
+		matches, err := versionMatcher(requirements[dir].Constraint)
+		if err != nil {
+			log.Debugf("Ignoring invalid constraint of %s: %v", dir, err)
+
+			continue
+		}
+
+		// installed versions are sorted newest first, as selectVersion expects them
+		product := ProductFor(dir).Name
+		matching := []*semver.Version{}
+		byVersion := map[*semver.Version]InstalledVersion{}
+		for _, v := range installed {
+			sv, err := semver.NewVersion(v.Version)
+			if err != nil || v.Product != product || !matches(sv) {
+				continue
+			}
+			matching = append(matching, sv)
+			byVersion[sv] = v
+		}
+
+		// the version the resolver would pick among the installed ones
+		if selected := selectVersion(ResolutionStrategy(), matching); selected != nil {
+			v := byVersion[selected]
+			log.Debugf("Keeping %s %s referenced by %s", v.Product, v.Version, dir)
+			referenced = append(referenced, v.Path)
+		}
+	}
+
+	return referenced, nil
+}
+
+// AutoPrune : prune with the configured policy when the auto_prune setting or SIMPLE_TFSWITCH_AUTO_PRUNE is set, at most once a day,
+// the binary at inUse, about to be used, is always kept
+func AutoPrune(inUse string) {
+	if !configBool(settingAutoPrune) {
+		return
+	}
+
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		log.Warnf("Skipping automatic prune: %v", err)
+
+		return
+	}
+
+	stamp := filepath.Join(installLocation, cacheDir, autoPruneStamp)
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < autoPruneEvery {
+		return
+	}
+
+	// an invalid policy is reported once a day, as the prune would run
+	policy, err := PrunePolicyFromConfig()
+	if err == nil {
+		err = policy.validate()
+	}
+	if err != nil {
+		log.Warnf("Skipping automatic prune: %v", err)
+		writeStamp(stamp)
+
+		return
+	}
+	policy.Protect = append(policy.Protect, inUse)
+
+	if _, err := Prune(policy); err != nil {
+		log.Warnf("Automatic prune failed: %v", err)
+
+		return
+	}
+	writeStamp(stamp)
+}
+
+// writeStamp : record the time of the automatic prune
+func writeStamp(stamp string) {
+	if err := os.MkdirAll(filepath.Dir(stamp), 0o755); err == nil {
+		_ = os.WriteFile(stamp, nil, 0o644)
+		markUsed(stamp)
+	}
+}
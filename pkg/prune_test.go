@@ -0,0 +1,213 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// fakeInstalled : pretend a version of the given size is installed and was last used some days ago
+func fakeInstalled(t *testing.T, version string, size int, daysAgo int) {
+	t.Helper()
+
+	fakeInstalledProduct(t, "terraform", version, size, daysAgo)
+}
+
+// fakeInstalledProduct : pretend a version of product is installed, see fakeInstalled
+func fakeInstalledProduct(t *testing.T, product string, version string, size int, daysAgo int) {
+	t.Helper()
+
+	path, err := pkg.InstalledPath(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(filepath.Dir(path), strings.Replace(filepath.Base(path), "terraform", product, 1))
+	if err := os.WriteFile(path, make([]byte, size), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	used := time.Now().Add(-time.Duration(daysAgo) * 24 * time.Hour)
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// installedPath : where a version of terraform is installed
+func installedPath(t *testing.T, version string) string {
+	t.Helper()
+
+	path, err := pkg.InstalledPath(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func expectPruned(t *testing.T, policy pkg.PrunePolicy, expected ...string) {
+	t.Helper()
+
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	fakeInstalled(t, "1.3.0", 100, 1)
+	fakeInstalled(t, "1.2.0", 100, 40)
+	fakeInstalled(t, "1.1.0", 100, 2)
+	fakeInstalled(t, "0.12.31", 100, 90)
+
+	// protected by version for brevity, by path for Prune
+	for i, v := range policy.Protect {
+		policy.Protect[i] = installedPath(t, v)
+	}
+
+	removed, err := pkg.Prune(policy)
+	if err != nil {
+		t.Fatalf("Unable to prune: %v (unexpected)", err)
+	}
+
+	versions := []string{}
+	for _, v := range removed {
+		versions = append(versions, v.Version)
+		if checkFileExist(v.Path) != policy.DryRun {
+			t.Errorf("Removed version %s still exists (unexpected)", v.Version)
+		}
+	}
+
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected %v to be removed, got %v (unexpected)", expected, versions)
+	}
+}
+
+// TestPrune : each retention policy keeps its versions, and they add up
+func TestPrune(t *testing.T) {
+	repo := t.TempDir()
+	module := filepath.Join(repo, "legacy")
+	createDirIfNotExist(module)
+	writeFile(t, filepath.Join(module, "main.tf"), "terraform {\n  required_version = \"< 0.13\"\n}\n")
+
+	expectPruned(t, pkg.PrunePolicy{KeepNewest: 2}, "1.1.0", "0.12.31")
+	expectPruned(t, pkg.PrunePolicy{KeepUsedWithin: 30 * 24 * time.Hour}, "1.2.0", "0.12.31")
+	expectPruned(t, pkg.PrunePolicy{KeepNewest: 1, KeepReferencedBy: []string{repo}}, "1.2.0", "1.1.0")
+	expectPruned(t, pkg.PrunePolicy{MaxSize: 250}, "0.12.31", "1.2.0")
+	expectPruned(t, pkg.PrunePolicy{MaxSize: 250, Protect: []string{"0.12.31"}}, "1.2.0", "1.1.0")
+	expectPruned(t, pkg.PrunePolicy{KeepNewest: 3, MaxSize: 250}, "0.12.31", "1.2.0")
+	expectPruned(t, pkg.PrunePolicy{KeepNewest: 3, DryRun: true}, "0.12.31")
+}
+
+// TestPruneAllProducts : the versions of every product are pruned, the newest ones being kept for each product
+func TestPruneAllProducts(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	fakeInstalled(t, "1.3.0", 100, 1)
+	fakeInstalled(t, "1.2.0", 100, 40)
+	fakeInstalledProduct(t, "tofu", "1.6.0", 100, 1)
+	fakeInstalledProduct(t, "tofu", "1.5.0", 100, 40)
+
+	removed, err := pkg.Prune(pkg.PrunePolicy{KeepNewest: 1})
+	if err != nil {
+		t.Fatalf("Unable to prune: %v (unexpected)", err)
+	}
+
+	versions := []string{}
+	for _, v := range removed {
+		versions = append(versions, v.Product+" "+v.Version)
+	}
+	if expected := []string{"terraform 1.2.0", "tofu 1.5.0"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected %v to be removed, got %v (unexpected)", expected, versions)
+	}
+}
+
+// TestPruneWithoutPolicy : nothing is removed without any rule
+func TestPruneWithoutPolicy(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	fakeInstalled(t, "1.3.0", 100, 1)
+
+	if _, err := pkg.Prune(pkg.PrunePolicy{Protect: []string{installedPath(t, "1.3.0")}}); err == nil {
+		t.Error("Prune without policy accepted (unexpected)")
+	}
+}
+
+// TestPruneReferencedStrategy : the version kept for a configuration is the one the strategy resolves it to
+func TestPruneReferencedStrategy(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "main.tf"), "terraform {\n  required_version = \">= 1.1\"\n}\n")
+
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", "")
+	expectPruned(t, pkg.PrunePolicy{KeepReferencedBy: []string{repo}}, "1.2.0", "1.1.0", "0.12.31")
+
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", "oldest")
+	expectPruned(t, pkg.PrunePolicy{KeepReferencedBy: []string{repo}}, "1.3.0", "1.2.0", "0.12.31")
+}
+
+// TestAutoPruneWithoutPolicy : an automatic prune without any rule removes nothing and is skipped for a day
+func TestAutoPruneWithoutPolicy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SNAP_USER_COMMON", home)
+	t.Setenv("SIMPLE_TFSWITCH_AUTO_PRUNE", "1")
+	for _, env := range []string{"SIMPLE_TFSWITCH_PRUNE_KEEP", "SIMPLE_TFSWITCH_PRUNE_KEEP_DAYS", "SIMPLE_TFSWITCH_PRUNE_REPOS", "SIMPLE_TFSWITCH_PRUNE_MAX_SIZE"} {
+		t.Setenv(env, "")
+	}
+	fakeInstalled(t, "1.3.0", 100, 1)
+	fakeInstalled(t, "1.2.0", 100, 40)
+
+	pkg.AutoPrune(installedPath(t, "1.3.0"))
+
+	if installed, err := pkg.GetInstalledVersions(); err != nil || len(installed) != 2 {
+		t.Errorf("Expected both versions to be kept, got %v: %v (unexpected)", installed, err)
+	}
+	if !checkFileExist(filepath.Join(home, ".terraform.versions", ".cache", "last-prune")) {
+		t.Error("Expected the skipped prune to be recorded, not reported again on each run (unexpected)")
+	}
+}
+
+// TestPrunePolicyFromConfig : the prune settings come from the configuration file and their environment variables
+func TestPrunePolicyFromConfig(t *testing.T) {
+	userDir := t.TempDir()
+	userFile := filepath.Join(userDir, "config.hcl")
+	writeConfig(t, userFile, `
+prune_keep     = 2
+prune_repos    = ["repo"]
+prune_max_size = "1G"
+`)
+	t.Setenv("SIMPLE_TFSWITCH_PRUNE_KEEP_DAYS", "30")
+	if err := loadConfig(t, userFile, t.TempDir()); err != nil {
+		t.Fatalf("Unable to load the configuration: %v (unexpected)", err)
+	}
+
+	policy, err := pkg.PrunePolicyFromConfig()
+	if err != nil {
+		t.Fatalf("Unable to read the prune policy: %v (unexpected)", err)
+	}
+	expected := pkg.PrunePolicy{
+		KeepNewest:       2,
+		KeepUsedWithin:   30 * 24 * time.Hour,
+		KeepReferencedBy: []string{filepath.Join(userDir, "repo")},
+		MaxSize:          1 << 30,
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("Expected %+v, got %+v (unexpected)", expected, policy)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_PRUNE_KEEP", "two")
+	if _, err := pkg.PrunePolicyFromConfig(); !errors.Is(err, pkg.ErrConfig) {
+		t.Errorf("Expected a configuration error for an invalid prune_keep, got %v (unexpected)", err)
+	}
+}
+
+// TestParseSize : sizes with binary units
+func TestParseSize(t *testing.T) {
+	for value, expected := range map[string]int64{"1024": 1024, "2K": 2048, "1.5M": 3 << 19, "2G": 2 << 30, "1GiB": 1 << 30, "3gb": 3 << 30} {
+		if size, err := pkg.ParseSize(value); err != nil || size != expected {
+			t.Errorf("Expected %d for %s, got %d: %v (unexpected)", expected, value, size, err)
+		}
+	}
+
+	for _, value := range []string{"", "G", "-1", "ten"} {
+		if _, err := pkg.ParseSize(value); err == nil {
+			t.Errorf("Invalid size %q accepted (unexpected)", value)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package pkg_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *openpgp.Entity
+)
+
+// testSigningKey : a locally generated release signing key, shared by all tests as generating one is slow
+func testSigningKey(t *testing.T) *openpgp.Entity {
+	t.Helper()
+
+	testKeyOnce.Do(func() {
+		key, err := openpgp.NewEntity("simple-tfswitch test", "", "test@example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testKey = key
+	})
+
+	return testKey
+}
+
+// armoredPublicKey : the armored public part of key
+func armoredPublicKey(t *testing.T, key *openpgp.Entity) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// trustTestKey : point the keyrings of both products to a fresh file trusting only the test signing key
+func trustTestKey(t *testing.T) {
+	t.Helper()
+
+	keyring := filepath.Join(t.TempDir(), "trusted-keys.asc")
+	if err := os.WriteFile(keyring, armoredPublicKey(t, testSigningKey(t)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIMPLE_TFSWITCH_KEYRING", keyring)
+	t.Setenv("SIMPLE_TFSWITCH_TOFU_KEYRING", keyring)
+}
+
+// detachSign : binary detached signature of content, as published by hashicorp
+func detachSign(t *testing.T, key *openpgp.Entity, content []byte) []byte {
+	t.Helper()
+
+	sig := &bytes.Buffer{}
+	if err := openpgp.DetachSign(sig, key, bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return sig.Bytes()
+}
+
+// releaseServer : a local stand-in for releases.hashicorp.com/terraform,
+// serving either the JSON releases index or only an HTML listing, and the files with or without ranges
+type releaseServer struct {
+	*httptest.Server
+	files    map[string][]byte
+	releases map[string]*pkg.Release
+	noIndex  bool
+	noRanges bool
+
+	mu     sync.Mutex
+	cut    map[string]int // files interrupted after this many bytes the next time they are served
+	ranges []string       // the Range headers received
+}
+
+// newReleaseServer : serve a release with a fake terraform binary for the current platform for each version,
+// signed with the test key which is trusted for the duration of the test
+func newReleaseServer(t *testing.T, versions ...string) *releaseServer {
+	t.Helper()
+	trustTestKey(t)
+
+	srv := &releaseServer{files: map[string][]byte{}, releases: map[string]*pkg.Release{}, cut: map[string]int{}}
+	for _, v := range versions {
+		srv.addRelease(t, v)
+	}
+
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func (s *releaseServer) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/terraform"), "/")
+
+	switch {
+	case path == "" && s.noIndex:
+		fmt.Fprintln(w, "<html><body><ul>")
+		for v := range s.releases {
+			fmt.Fprintf(w, "<li>\n<a href=\"/terraform/%s/\">terraform_%s</a>\n</li>\n", v, v)
+		}
+		fmt.Fprintln(w, "</ul></body></html>")
+	case path == "index.json" && !s.noIndex:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "terraform", "versions": s.releases})
+	case strings.HasSuffix(path, "/index.json") && !s.noIndex:
+		release, ok := s.releases[strings.TrimSuffix(path, "/index.json")]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+		_ = json.NewEncoder(w).Encode(release)
+	default:
+		content, ok := s.files[path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+		s.serveFile(w, r, path, content)
+	}
+}
+
+// serveFile : serve a file, with ranges validated by its ETag unless noRanges is set,
+// or only its beginning when it is to be cut
+func (s *releaseServer) serveFile(w http.ResponseWriter, r *http.Request, path string, content []byte) {
+	s.mu.Lock()
+	cut, interrupted := s.cut[path]
+	delete(s.cut, path)
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256(content)
+	if !s.noRanges {
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	}
+
+	if interrupted {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if !s.noRanges {
+			w.Header().Set("Accept-Ranges", "bytes")
+		}
+		_, _ = w.Write(content[:cut])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+
+	if s.noRanges {
+		_, _ = w.Write(content)
+
+		return
+	}
+	http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(content))
+}
+
+// cutNext : interrupt the next download of the zip of a version at half its size, return the zip path
+func (s *releaseServer) cutNext(version string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path, content := range s.files {
+		if strings.HasPrefix(path, version+"/") && strings.HasSuffix(path, ".zip") {
+			s.cut[path] = len(content) / 2
+
+			return path
+		}
+	}
+
+	return ""
+}
+
+// rangesReceived : the Range headers received so far, "" for the requests without one
+func (s *releaseServer) rangesReceived() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.ranges...)
+}
+
+// addRelease : publish the zip and the signed SHA256SUMS of a version
+func (s *releaseServer) addRelease(t *testing.T, version string) {
+	t.Helper()
+
+	zipName := fmt.Sprintf("terraform_%s_%s_%s.zip", version, runtime.GOOS, runtime.GOARCH)
+	zipContent := fakeTerraformZip(t, version)
+	sum := sha256.Sum256(zipContent)
+
+	s.files[version+"/"+zipName] = zipContent
+	s.setSHA256Sums(t, version, []byte(hex.EncodeToString(sum[:])+"  "+zipName+"\n"))
+	s.releases[version] = &pkg.Release{
+		Name:             "terraform",
+		Version:          version,
+		Shasums:          "terraform_" + version + "_SHA256SUMS",
+		ShasumsSignature: "terraform_" + version + "_SHA256SUMS.sig",
+		Builds: []pkg.Build{{
+			Name: "terraform", Version: version, OS: runtime.GOOS, Arch: runtime.GOARCH,
+			Filename: zipName, URL: "/terraform/" + version + "/" + zipName,
+		}},
+	}
+}
+
+// setSHA256Sums : publish the SHA256SUMS of a version along with its signature
+func (s *releaseServer) setSHA256Sums(t *testing.T, version string, content []byte) {
+	t.Helper()
+
+	shasums := version + "/terraform_" + version + "_SHA256SUMS"
+	s.files[shasums] = content
+	s.files[shasums+".sig"] = detachSign(t, testSigningKey(t), content)
+}
+
+// source : the release source to give to the installer
+func (s *releaseServer) source() pkg.Source {
+	return &pkg.HTTPSource{URL: s.URL + "/terraform/"}
+}
+
+// fakeTerraformZip : build a release zip with a terraform "binary" printing its version
+func fakeTerraformZip(t *testing.T, version string) []byte {
+	t.Helper()
+
+	return fakeProductZip(t, "terraform", version)
+}
+
+// fakeProductZip : build a release zip with a "binary" of the product name printing its version
+func fakeProductZip(t *testing.T, name string, version string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if runtime.GOOS == "windows" {
+		header.Name = name + ".exe"
+	}
+	header.SetMode(0o755)
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("#!/bin/sh\necho " + name + " v" + version + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// newer releases ship their license next to the binary
+	w, err = zw.Create("LICENSE.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("license\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// writeFiles : copy the release files to dir, in version folders as on the mirror or flat as downloaded
+func (s *releaseServer) writeFiles(t *testing.T, dir string, flat bool) {
+	t.Helper()
+
+	for name, content := range s.files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if flat {
+			path = filepath.Join(dir, filepath.Base(name))
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tofuServer : a local stand-in for the OpenTofu releases on GitHub and their versions index,
+// with armored signatures as OpenTofu publishes them
+type tofuServer struct {
+	*httptest.Server
+	files    map[string][]byte
+	versions []string
+}
+
+// newTofuServer : serve a release with a fake tofu binary for the current platform for each version,
+// signed with the test key which is trusted for the duration of the test
+func newTofuServer(t *testing.T, versions ...string) *tofuServer {
+	t.Helper()
+	trustTestKey(t)
+
+	srv := &tofuServer{files: map[string][]byte{}, versions: versions}
+	for _, v := range versions {
+		zipName := fmt.Sprintf("tofu_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+		zipContent := fakeProductZip(t, "tofu", v)
+		sum := sha256.Sum256(zipContent)
+		shasums := []byte(hex.EncodeToString(sum[:]) + "  " + zipName + "\n")
+
+		sig := &bytes.Buffer{}
+		if err := openpgp.ArmoredDetachSign(sig, testSigningKey(t), bytes.NewReader(shasums), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := "/opentofu/releases/download/v" + v + "/"
+		srv.files[dir+zipName] = zipContent
+		srv.files[dir+"tofu_"+v+"_SHA256SUMS"] = shasums
+		srv.files[dir+"tofu_"+v+"_SHA256SUMS.gpgsig"] = sig.Bytes()
+	}
+
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func (s *tofuServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/tofu/api.json" {
+		index := map[string][]map[string]string{"versions": {}}
+		for _, v := range s.versions {
+			index["versions"] = append(index["versions"], map[string]string{"id": v})
+		}
+		_ = json.NewEncoder(w).Encode(index)
+
+		return
+	}
+
+	content, ok := s.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+	_, _ = w.Write(content)
+}
+
+// location : the mirror location of the releases, as configured
+func (s *tofuServer) location() string {
+	return s.URL + "/opentofu/releases/download?index=" + s.URL + "/tofu/api.json"
+}
+
+// addBuild : publish a build of a version for another platform, a zip which only has to be hashed
+func (s *releaseServer) addBuild(t *testing.T, version string, goos string, goarch string) {
+	t.Helper()
+
+	zipName := fmt.Sprintf("terraform_%s_%s_%s.zip", version, goos, goarch)
+	zipContent := []byte(zipName)
+	sum := sha256.Sum256(zipContent)
+
+	s.files[version+"/"+zipName] = zipContent
+	shasums := s.files[version+"/terraform_"+version+"_SHA256SUMS"]
+	s.setSHA256Sums(t, version, append(append([]byte{}, shasums...), hex.EncodeToString(sum[:])+"  "+zipName+"\n"...))
+	s.releases[version].Builds = append(s.releases[version].Builds, pkg.Build{
+		Name: "terraform", Version: version, OS: goos, Arch: goarch,
+		Filename: zipName, URL: "/terraform/" + version + "/" + zipName,
+	})
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+const indexFile = "index.json"
+
+// errNoIndex : the mirror does not serve the releases JSON index
+var errNoIndex = errors.New("no releases index")
+
+// Build : an artifact of a release for one os/arch
+type Build struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+// Release : a released version as described by the releases index,
+// a release without builds comes from a source without index and follows the default layout
+type Release struct {
+	Name             string  `json:"name"`
+	Version          string  `json:"version"`
+	Shasums          string  `json:"shasums"`
+	ShasumsSignature string  `json:"shasums_signature"` //nolint:tagliatelle // releases index format
+	Builds           []Build `json:"builds"`
+
+	// BaseURL : the url the file names of the release are relative to
+	BaseURL string `json:"base_url,omitempty"` //nolint:tagliatelle // see above
+	// ShasumsURL : absolute url of the SHA256SUMS file
+	ShasumsURL string `json:"-"`
+	// SignatureURL : absolute url of the detached signature of the SHA256SUMS file
+	SignatureURL string `json:"-"`
+}
+
+// productIndex : the releases index of a product, index.json at the root of the mirror
+type productIndex struct {
+	Name     string              `json:"name"`
+	Versions map[string]*Release `json:"versions"`
+}
+
+// Catalog : the releases of a mirror, newest first
+type Catalog struct {
+	MirrorURL string
+	Releases  []*Release
+}
+
+// fetchCatalog : get the releases available from a source, newest first
+func fetchCatalog(src Source) (*Catalog, error) {
+	releases, err := src.Releases()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].semver().GreaterThan(releases[j].semver())
+	})
+
+	return &Catalog{MirrorURL: src.String(), Releases: releases}, nil
+}
+
+// Versions : the versions of the catalog, newest first, including pre-releases or not
+func (c *Catalog) Versions(preRelease bool) []string {
+	versions := make([]string, 0, len(c.Releases))
+	for _, r := range c.Releases {
+		if !preRelease && r.semver().Prerelease() != "" {
+			continue
+		}
+		versions = append(versions, r.Version)
+	}
+
+	return versions
+}
+
+// Build : the build of the release for os/arch
+func (r *Release) Build(goos string, goarch string) (*Build, error) {
+	if r.Builds == nil {
+		filename := fmt.Sprintf("%s_%s_%s_%s.zip", r.Name, r.Version, goos, goarch)
+
+		return &Build{
+			Name: r.Name, Version: r.Version, OS: goos, Arch: goarch,
+			Filename: filename, URL: resolveURL(r.BaseURL, filename),
+		}, nil
+	}
+
+	for i := range r.Builds {
+		if r.Builds[i].OS == goos && r.Builds[i].Arch == goarch {
+			return &r.Builds[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s %s has no build for %s_%s", ErrNoMatchingVersion, r.Name, r.Version, goos, goarch)
+}
+
+// resolve : fill the defaults and absolute urls of a release of product, relative to baseURL
+func (r *Release) resolve(baseURL string, product *Product) {
+	r.BaseURL = baseURL
+	if r.Name == "" {
+		r.Name = product.Name
+	}
+	if r.Shasums == "" {
+		r.Shasums = fmt.Sprintf("%s_%s_SHA256SUMS", r.Name, r.Version)
+	}
+	r.ShasumsURL = resolveURL(baseURL, r.Shasums)
+	r.SignatureURL = r.ShasumsURL + product.SignatureExt
+
+	for i := range r.Builds {
+		if r.Builds[i].URL == "" {
+			r.Builds[i].URL = r.Builds[i].Filename
+		}
+		r.Builds[i].URL = resolveURL(baseURL, r.Builds[i].URL)
+	}
+}
+
+// semver : the parsed version, releases are only created from valid versions
+func (r *Release) semver() *semver.Version {
+	v, _ := semver.NewVersion(r.Version)
+
+	return v
+}
+
+// resolveURL : resolve ref against base, ref is kept as is when it cannot be parsed
+func resolveURL(base string, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	return b.ResolveReference(r).String()
+}
+
+// withSlash : append a trailing slash to url if missing
+func withSlash(url string) string {
+	if !strings.HasSuffix(url, "/") {
+		return url + "/"
+	}
+
+	return url
+}
@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	cacheDir        = ".cache"
+	cacheTTLEnv     = "SIMPLE_TFSWITCH_CACHE_TTL"
+	defaultCacheTTL = time.Hour
+	offlineEnv      = "SIMPLE_TFSWITCH_OFFLINE"
+	maxRefreshWait  = 10 * time.Second
+)
+
+// refreshes : the background refreshes of stale caches, to be waited for before the process exits or execs
+var refreshes sync.WaitGroup //nolint:gochecknoglobals // shared by all the catalogs of the process
+
+// cachedCatalog : the catalog of a mirror as stored on disk
+type cachedCatalog struct {
+	MirrorURL string     `json:"mirror_url"` //nolint:tagliatelle // snake case like the releases index
+	FetchedAt time.Time  `json:"fetched_at"` //nolint:tagliatelle // see above
+	Releases  []*Release `json:"releases"`
+}
+
+// Offline : the network must never be used, set by the offline setting or SIMPLE_TFSWITCH_OFFLINE
+func Offline() bool {
+	return configBool(settingOffline)
+}
+
+// CacheTTL : how long the list of versions of a mirror is used before being refreshed,
+// set by the cache_ttl setting or SIMPLE_TFSWITCH_CACHE_TTL as a duration (1h, 30m, ...), 0 to always refresh
+func CacheTTL() time.Duration {
+	return configDuration(settingCacheTTL, defaultCacheTTL)
+}
+
+// GetCatalog : get the releases available from a source.
+// The releases of a remote source come from the on-disk cache when fresh enough:
+// a stale cache is used as is and refreshed in the background, see WaitRefreshes, in offline mode it is never refreshed.
+func GetCatalog(src Source) (*Catalog, error) {
+	// local sources are always up to date, and available offline
+	if !isRemote(src) {
+		return fetchCatalog(src)
+	}
+
+	mirrorURL := src.String()
+	cacheFile, err := catalogCacheFile(mirrorURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := readCachedCatalog(cacheFile)
+	if err != nil {
+		log.Debugf("Unable to read cached versions of %s: %v", mirrorURL, err)
+	}
+
+	switch {
+	case cached != nil && Offline():
+		log.Debugf("Offline, using versions of %s cached at %v", mirrorURL, cached.FetchedAt)
+	case cached == nil && Offline():
+		return nil, fmt.Errorf("no cached list of versions for %s: %w", mirrorURL, ErrOffline)
+	case cached == nil:
+		return refreshCatalog(src, cacheFile)
+	case time.Since(cached.FetchedAt) > CacheTTL():
+		log.Debugf("Cached versions of %s are stale, refreshing in the background", mirrorURL)
+		refreshes.Add(1)
+		go func() {
+			defer refreshes.Done()
+			if _, err := refreshCatalog(src, cacheFile); err != nil {
+				log.Debugf("Unable to refresh versions of %s: %v", mirrorURL, err)
+			}
+		}()
+	default:
+		log.Debugf("Using versions of %s cached at %v", mirrorURL, cached.FetchedAt)
+	}
+
+	for _, r := range cached.Releases {
+		r.resolve(r.BaseURL, productOf(src))
+	}
+
+	return &Catalog{MirrorURL: mirrorURL, Releases: cached.Releases}, nil
+}
+
+// WaitRefreshes : wait for the refreshes of stale caches running in the background, at most 10s,
+// before the process exits or is replaced by terraform, which would stop them
+func WaitRefreshes() {
+	done := make(chan struct{})
+	go func() {
+		refreshes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(maxRefreshWait):
+		log.Debugf("Cache refresh still running after %v, leaving it for a next run", maxRefreshWait)
+	}
+}
+
+// refreshCatalog : fetch the catalog of the mirror and store it in the cache
+func refreshCatalog(src Source, cacheFile string) (*Catalog, error) {
+	catalog, err := fetchCatalog(src)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &cachedCatalog{MirrorURL: src.String(), FetchedAt: time.Now(), Releases: catalog.Releases}
+	if err := writeCachedCatalog(cacheFile, cached); err != nil {
+		log.Warnf("Unable to cache versions of %s: %v", src, err)
+	}
+
+	return catalog, nil
+}
+
+// catalogCacheFile : the cache file of a mirror
+func catalogCacheFile(mirrorURL string) (string, error) {
+	installLocation, err := getInstallLocation()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(mirrorURL))
+
+	return filepath.Join(installLocation, cacheDir, "releases-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func readCachedCatalog(cacheFile string) (*cachedCatalog, error) {
+	content, err := os.ReadFile(cacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &cachedCatalog{}
+	if err := json.Unmarshal(content, cached); err != nil {
+		return nil, err
+	}
+
+	return cached, nil
+}
+
+// writeCachedCatalog : write the cache of a catalog
+func writeCachedCatalog(cacheFile string, cached *cachedCatalog) error {
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(cacheFile, content)
+}
+
+// writeFileAtomic : write a file through a temporary file so readers never see a partial file
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,81 @@
+package pkg_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestGetCatalogCache : the list of versions is only downloaded again once the cache is stale
+func TestGetCatalogCache(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "")
+	t.Setenv("SIMPLE_TFSWITCH_CACHE_TTL", "1h")
+	srv := newReleaseServer(t, "1.1.0")
+
+	expectVersions := func(expected ...string) {
+		t.Helper()
+
+		versions, err := pkg.GetTFList(srv.source(), true)
+		if err != nil {
+			t.Fatalf("Unable to get list: %v (unexpected)", err)
+		}
+		if !reflect.DeepEqual(versions, expected) {
+			t.Errorf("Expected versions %v, got %v (unexpected)", expected, versions)
+		}
+	}
+
+	expectVersions("1.1.0")
+
+	// still cached
+	srv.addRelease(t, "1.2.0")
+	expectVersions("1.1.0")
+
+	// stale: the cached list is used while it is refreshed in the background
+	t.Setenv("SIMPLE_TFSWITCH_CACHE_TTL", "0s")
+	expectVersions("1.1.0")
+
+	// the refresh is over once waited for, as before the process exits
+	pkg.WaitRefreshes()
+	t.Setenv("SIMPLE_TFSWITCH_CACHE_TTL", "1h")
+	expectVersions("1.2.0", "1.1.0")
+
+	// offline: the cache is used whatever its age
+	srv.addRelease(t, "1.3.0")
+	t.Setenv("SIMPLE_TFSWITCH_CACHE_TTL", "0s")
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "1")
+	expectVersions("1.2.0", "1.1.0")
+}
+
+// TestOffline : nothing is downloaded in offline mode
+func TestOffline(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "true")
+	srv := newReleaseServer(t, "1.1.0")
+
+	if _, err := pkg.GetTFList(srv.source(), true); !errors.Is(err, pkg.ErrOffline) {
+		t.Errorf("Expected an offline error without cache, got %v (unexpected)", err)
+	}
+
+	if _, err := pkg.Install("1.1.0", srv.source()); !errors.Is(err, pkg.ErrOffline) {
+		t.Errorf("Expected an offline error for a version not installed, got %v (unexpected)", err)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "")
+	installed, err := pkg.Install("1.1.0", srv.source())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "1")
+	if again, err := pkg.Install("1.1.0", srv.source()); err != nil || again != installed {
+		t.Errorf("Installed version not usable offline: %v (unexpected)", err)
+	}
+
+	if _, err := pkg.DownloadFromURL(filepath.Dir(installed), srv.URL+"/terraform/"); !errors.Is(err, pkg.ErrOffline) {
+		t.Errorf("Expected an offline error for a download, got %v (unexpected)", err)
+	}
+}
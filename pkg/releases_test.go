@@ -0,0 +1,88 @@
+package pkg_test
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestGetCatalog : versions are read from the releases index, newest first
+func TestGetCatalog(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "0.12.31", "1.3.0-alpha20220622", "1.2.9", "1.10.0")
+
+	catalog, err := pkg.GetCatalog(srv.source())
+	if err != nil {
+		t.Fatalf("Unable to get catalog: %v (unexpected)", err)
+	}
+
+	expected := []string{"1.10.0", "1.3.0-alpha20220622", "1.2.9", "0.12.31"}
+	if versions := catalog.Versions(true); !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected versions %v, got %v (unexpected)", expected, versions)
+	}
+
+	expected = []string{"1.10.0", "1.2.9", "0.12.31"}
+	if versions := catalog.Versions(false); !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected stable versions %v, got %v (unexpected)", expected, versions)
+	}
+
+	build, err := catalog.Releases[0].Build(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Fatalf("No build for current platform: %v (unexpected)", err)
+	}
+
+	expectedURL := srv.URL + "/terraform/1.10.0/terraform_1.10.0_" + runtime.GOOS + "_" + runtime.GOARCH + ".zip"
+	if build.URL != expectedURL {
+		t.Errorf("Expected build url %s, got %s (unexpected)", expectedURL, build.URL)
+	}
+
+	if _, err := catalog.Releases[0].Build("plan9", "mips"); err == nil {
+		t.Error("Found a build for a platform not released (unexpected)")
+	}
+}
+
+// TestGetCatalogHTML : mirrors without index fall back to the HTML listing
+func TestGetCatalogHTML(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "0.12.31", "1.0.0-rc1", "1.2.9")
+	srv.noIndex = true
+
+	versions, err := pkg.GetTFList(srv.source(), true)
+	if err != nil {
+		t.Fatalf("Unable to get list: %v (unexpected)", err)
+	}
+
+	expected := []string{"1.2.9", "1.0.0-rc1", "0.12.31"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected versions %v, got %v (unexpected)", expected, versions)
+	}
+}
+
+// TestGetRelease : a single release is described by its own index or by the default layout
+func TestGetRelease(t *testing.T) {
+	srv := newReleaseServer(t, "1.2.9")
+
+	for _, noIndex := range []bool{false, true} {
+		srv.noIndex = noIndex
+
+		release, err := srv.source().Release("1.2.9")
+		if err != nil {
+			t.Fatalf("Unable to get release: %v (unexpected)", err)
+		}
+
+		if release.ShasumsURL != srv.URL+"/terraform/1.2.9/terraform_1.2.9_SHA256SUMS" {
+			t.Errorf("Unexpected SHA256SUMS url %s", release.ShasumsURL)
+		}
+
+		build, err := release.Build(runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			t.Fatalf("No build for current platform: %v (unexpected)", err)
+		}
+
+		if build.URL != srv.URL+"/terraform/1.2.9/"+build.Filename {
+			t.Errorf("Unexpected build url %s", build.URL)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// RequiredVersion : a required_version constraint and the file declaring it
+type RequiredVersion struct {
+	File       string
+	Constraint string
+}
+
+func (r RequiredVersion) String() string {
+	return fmt.Sprintf("%s (%s)", r.File, r.Constraint)
+}
+
+// LoadRequiredVersions : every required_version of the module in dir, as read by the product used in dir
+func LoadRequiredVersions(dir string) ([]RequiredVersion, error) {
+	files, err := moduleFiles(dir, ProductFor(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	required := []RequiredVersion{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		var f *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(file, ".json") {
+			f, diags = parser.ParseJSONFile(file)
+		} else {
+			f, diags = parser.ParseHCLFile(file)
+		}
+		if diags.HasErrors() || f == nil {
+			// leave the files the HCL2 parser does not understand to tfconfig legacy loader
+			return loadModuleRequiredVersions(dir), nil
+		}
+
+		mod := tfconfig.NewModule(dir)
+		_ = tfconfig.LoadModuleFromFile(f, mod)
+		for _, constraint := range mod.RequiredCore {
+			required = append(required, RequiredVersion{File: file, Constraint: constraint})
+		}
+	}
+
+	return required, nil
+}
+
+// loadModuleRequiredVersions : required versions of the whole module, without file information
+func loadModuleRequiredVersions(dir string) []RequiredVersion {
+	module, _ := tfconfig.LoadModule(dir)
+
+	required := make([]RequiredVersion, len(module.RequiredCore))
+	for i, constraint := range module.RequiredCore {
+		required[i] = RequiredVersion{File: dir, Constraint: constraint}
+	}
+
+	return required
+}
+
+// moduleFiles : the configuration files of dir, as read by product:
+// OpenTofu reads a.tofu instead of a.tf, and a.tofu.json instead of a.tf.json
+func moduleFiles(dir string, product *Product) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the extension of each file name, the last one of the product winning
+	chosen := map[string]int{}
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		for i, ext := range product.Extensions {
+			if !strings.HasSuffix(name, ext) {
+				continue
+			}
+			// a.tf and a.tofu replace each other, a.tf.json and a.tofu.json too
+			key := strings.TrimSuffix(name, ext)
+			if strings.HasSuffix(ext, ".json") {
+				key += ".json"
+			}
+			if previous, ok := chosen[key]; !ok {
+				names = append(names, key)
+				chosen[key] = i
+			} else if i > previous {
+				chosen[key] = i
+			}
+		}
+	}
+
+	files := make([]string, len(names))
+	for i, key := range names {
+		files[i] = filepath.Join(dir, strings.TrimSuffix(key, ".json")+product.Extensions[chosen[key]])
+	}
+
+	return files, nil
+}
+
+// joinRequiredVersions : a single constraint satisfied only by versions satisfying every required version
+func joinRequiredVersions(required []RequiredVersion) string {
+	constraints := make([]string, len(required))
+	for i, r := range required {
+		constraints[i] = r.Constraint
+	}
+
+	return strings.Join(constraints, ", ")
+}
+
+// CheckRequiredVersions : check that at least one of versions satisfies all the required versions,
+// otherwise report the conflicting ones
+func CheckRequiredVersions(required []RequiredVersion, versions []string) error {
+	constraints := make([]*semver.Constraints, len(required))
+	for i, r := range required {
+		c, err := semver.NewConstraint(r.Constraint)
+		if err != nil {
+			return opError(ErrInvalidVersion, "invalid required_version in "+r.String(), err)
+		}
+		constraints[i] = c
+	}
+
+	parsed := make([]*semver.Version, 0, len(versions))
+	for _, version := range versions {
+		if v, err := semver.NewVersion(version); err == nil {
+			parsed = append(parsed, v)
+		}
+	}
+
+	if satisfiable(parsed, constraints...) {
+		return nil
+	}
+
+	conflicts := []string{}
+	for i := range constraints {
+		for j := i + 1; j < len(constraints); j++ {
+			if !satisfiable(parsed, constraints[i], constraints[j]) {
+				conflicts = append(conflicts, fmt.Sprintf("%s conflicts with %s", required[i], required[j]))
+			}
+		}
+	}
+
+	if len(conflicts) == 0 {
+		conflicts = append(conflicts, fmt.Sprintf("no version satisfies %s", joinRequiredVersionSources(required)))
+	}
+
+	return fmt.Errorf("%w: required_version constraints cannot all be satisfied: %s", ErrNoMatchingVersion, strings.Join(conflicts, "; "))
+}
+
+// satisfiable : one of versions satisfies all constraints
+func satisfiable(versions []*semver.Version, constraints ...*semver.Constraints) bool {
+	for _, v := range versions {
+		ok := true
+		for _, c := range constraints {
+			if !c.Check(v) {
+				ok = false
+
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinRequiredVersionSources(required []RequiredVersion) string {
+	sources := make([]string, len(required))
+	for i, r := range required {
+		sources[i] = r.String()
+	}
+
+	return strings.Join(sources, ", ")
+}
@@ -0,0 +1,91 @@
+package pkg_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestLoadRequiredVersions : every required_version of every file is kept
+func TestLoadRequiredVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.tf"), "terraform {\n  required_version = \">= 1.1\"\n}\n")
+	writeFile(t, filepath.Join(dir, "versions.tf.json"), `{"terraform": {"required_version": "< 1.3"}}`)
+	writeFile(t, filepath.Join(dir, "outputs.tf"), "output \"a\" {\n  value = 1\n}\n")
+	writeFile(t, filepath.Join(dir, ".hidden.tf"), "terraform {\n  required_version = \"0.11.0\"\n}\n")
+
+	required, err := pkg.LoadRequiredVersions(dir)
+	if err != nil {
+		t.Fatalf("Unable to load required versions: %v (unexpected)", err)
+	}
+
+	expected := []pkg.RequiredVersion{
+		{File: filepath.Join(dir, "main.tf"), Constraint: ">= 1.1"},
+		{File: filepath.Join(dir, "versions.tf.json"), Constraint: "< 1.3"},
+	}
+	if len(required) != len(expected) || required[0] != expected[0] || required[1] != expected[1] {
+		t.Errorf("Expected %v, got %v (unexpected)", expected, required)
+	}
+
+	req, err := pkg.FindVersionRequirement(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Constraint != ">= 1.1, < 1.3" {
+		t.Errorf("Expected combined constraint, got %q (unexpected)", req.Constraint)
+	}
+}
+
+// TestCheckRequiredVersions : conflicting constraints are reported with their files
+func TestCheckRequiredVersions(t *testing.T) {
+	versions := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0"}
+
+	compatible := []pkg.RequiredVersion{{"a.tf", ">= 1.1"}, {"b.tf", "< 1.3"}}
+	if err := pkg.CheckRequiredVersions(compatible, versions); err != nil {
+		t.Errorf("Compatible constraints rejected: %v (unexpected)", err)
+	}
+
+	conflicting := []pkg.RequiredVersion{{"a.tf", ">= 1.2"}, {"b.tf", "!= 1.3.0"}, {"c.tf", "< 1.1"}}
+	err := pkg.CheckRequiredVersions(conflicting, versions)
+	if err == nil {
+		t.Fatal("Conflicting constraints accepted (unexpected)")
+	}
+
+	if !strings.Contains(err.Error(), "a.tf (>= 1.2) conflicts with c.tf (< 1.1)") {
+		t.Errorf("Conflict not reported: %v (unexpected)", err)
+	}
+
+	if strings.Contains(err.Error(), "b.tf (!= 1.3.0) conflicts") {
+		t.Errorf("Compatible constraint reported as conflict: %v (unexpected)", err)
+	}
+}
+
+// TestInstallConflictingRequiredVersions : nothing is installed when the constraints conflict
+func TestInstallConflictingRequiredVersions(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("TFSWITCH_VERSION", "")
+	srv := newReleaseServer(t, "1.1.0", "1.2.3")
+
+	dir := t.TempDir()
+	createDirIfNotExist(filepath.Join(dir, ".git"))
+	writeFile(t, filepath.Join(dir, "main.tf"), "terraform {\n  required_version = \">= 1.2\"\n}\n")
+	writeFile(t, filepath.Join(dir, "versions.tf"), "terraform {\n  required_version = \"< 1.2\"\n}\n")
+
+	if _, err := pkg.InstallTFProvidedModule(dir, srv.source()); err == nil {
+		t.Fatal("Conflicting constraints installed a version (unexpected)")
+	}
+
+	writeFile(t, filepath.Join(dir, "versions.tf"), "terraform {\n  required_version = \"< 1.3\"\n}\n")
+
+	installed, err := pkg.InstallTFProvidedModule(dir, srv.source())
+	if err != nil {
+		t.Fatalf("Unable to install: %v (unexpected)", err)
+	}
+
+	if filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_1.2.3") {
+		t.Errorf("Expected 1.2.3 to be installed, got %s (unexpected)", installed)
+	}
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// ScanRequirements : walk root and return the version requirement of every directory declaring one,
+// either through a version file, terraform files or a terragrunt.hcl, by directory.
+// The TFSWITCH_VERSION override does not apply here, only what the repository declares, its lock file included.
+func ScanRequirements(root string) (map[string]*VersionRequirement, error) {
+	requirements := map[string]*VersionRequirement{}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && skipScanDir(d.Name()) {
+			return filepath.SkipDir
+		}
+
+		req, err := dirVersionRequirement(path)
+		if err != nil || req == nil {
+			return err
+		}
+		if req, err = applyLock(path, req); err != nil {
+			return err
+		}
+		requirements[path] = req
+
+		return nil
+	})
+
+	return requirements, err
+}
+
+// SortedDirs : the directories of requirements, sorted
+func SortedDirs(requirements map[string]*VersionRequirement) []string {
+	dirs := make([]string, 0, len(requirements))
+	for dir := range requirements {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// dirVersionRequirement : the requirement of dir as found from it, nil when dir declares none itself:
+// its own version file, else a version file found up to the repository root for its configuration
+func dirVersionRequirement(dir string) (*VersionRequirement, error) {
+	product := ProductFor(dir)
+	req, err := readTerraformVersion(filepath.Join(dir, product.VersionFile))
+	if err != nil || req != nil {
+		return req, err
+	}
+
+	req, err = readToolVersions(filepath.Join(dir, toolVersions), product)
+	if err != nil || req != nil {
+		return req, err
+	}
+
+	module, err := moduleRequirement(dir)
+	if err != nil || module == nil {
+		return nil, err
+	}
+
+	// a version file wins over the configuration, as when running in dir
+	req, err = findVersionFile(dir, product)
+	if err != nil || req != nil {
+		return req, err
+	}
+
+	return module, nil
+}
+
+// skipScanDir : directories never holding configurations of their own
+func skipScanDir(name string) bool {
+	switch name {
+	case ".git", ".terraform", terragruntCache, "node_modules":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // still the only way to read hashicorp detached signatures without cgo
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // see above
+)
+
+const (
+	keyringEnv      = "SIMPLE_TFSWITCH_KEYRING"
+	keyringFile     = "trusted-keys.asc"
+	tofuKeyringFile = "trusted-tofu-keys.asc"
+)
+
+// SignatureError : the signature of a release could not be verified
+type SignatureError struct {
+	URL string
+	Err error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature verification failed for %s: %v", e.URL, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// Is : a SignatureError is an ErrChecksum
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+// KeyringPath : location of the keyring file holding the extra keys trusted to sign the releases of product,
+// set by the keyring setting or SIMPLE_TFSWITCH_KEYRING for terraform, <user config dir>/simple-tfswitch/trusted-keys.asc
+// by default, and by the tofu_keyring setting or SIMPLE_TFSWITCH_TOFU_KEYRING for tofu,
+// <user config dir>/simple-tfswitch/trusted-tofu-keys.asc by default
+func KeyringPath(product *Product) (string, error) {
+	path := configString(product.keyringSetting)
+	if path == "" {
+		return "", fmt.Errorf("%w: unable to find the user config directory, set %s", ErrConfig, product.keyringSetting)
+	}
+
+	return path, nil
+}
+
+// defaultKeyringPath : file in <user config dir>/simple-tfswitch, "" when there is no user config dir
+func defaultKeyringPath(file string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, configDir, file)
+}
+
+// TrustedKeyring : the keys trusted to sign the releases of product, its embedded key plus every key
+// found in its keyring file. Each product has its own keys: the key of one never validates the releases of the other.
+func TrustedKeyring(product *Product) (openpgp.EntityList, error) {
+	keyring := openpgp.EntityList{}
+	if product.signingKey != "" {
+		embedded, err := openpgp.ReadArmoredKeyRing(strings.NewReader(product.signingKey))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read embedded %s key: %w", product.Name, err)
+		}
+		keyring = append(keyring, embedded...)
+	}
+
+	path, err := KeyringPath(product)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keyring, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := readArmoredKeys(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keyring %s: %w", path, err)
+	}
+	log.Debugf("Loaded %d trusted %s keys from %s", len(extra), product.Name, path)
+
+	return append(keyring, extra...), nil
+}
+
+// AddTrustedKeys : validate armored public keys and append them to the keyring file of product
+func AddTrustedKeys(product *Product, armored []byte) error {
+	keys, err := readArmoredKeys(armored)
+	if err != nil {
+		return err
+	}
+
+	path, err := KeyringPath(product)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, key := range keys {
+		w, err := armor.Encode(f, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return err
+		}
+		if err := key.Serialize(w); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if _, err := f.WriteString("\n"); err != nil {
+			return err
+		}
+		log.Infof("Added trusted key %X to %s", key.PrimaryKey.Fingerprint, path)
+	}
+
+	return nil
+}
+
+// readArmoredKeys : read all armored public key blocks of content
+func readArmoredKeys(content []byte) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+
+	for {
+		block, err := armor.Decode(bytes.NewReader(content))
+		if err != nil {
+			break
+		}
+
+		entities, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, entities...)
+
+		// armor.Decode stops after the first block, continue with the rest of the content
+		end := bytes.Index(content, []byte("-----END PGP PUBLIC KEY BLOCK-----"))
+		if end < 0 {
+			break
+		}
+		content = content[end+len("-----END PGP PUBLIC KEY BLOCK-----"):]
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no armored public key found")
+	}
+
+	return keys, nil
+}
+
+// VerifySignature : check the detached signature of content, binary or armored, against the keys trusted for product
+func VerifySignature(product *Product, url string, content []byte, signature []byte) error {
+	keyring, err := TrustedKeyring(product)
+	if err != nil {
+		return err
+	}
+	if len(keyring) == 0 {
+		path, _ := KeyringPath(product)
+		if product.signingKeyURL != "" {
+			return &SignatureError{URL: url, Err: fmt.Errorf("no key trusted to sign %s releases, add the key published at %s to %s",
+				product.Title, product.signingKeyURL, path)}
+		}
+
+		return &SignatureError{URL: url, Err: fmt.Errorf("no key trusted to sign %s releases, add it to %s", product.Title, path)}
+	}
+
+	check := openpgp.CheckDetachedSignature
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		// OpenTofu publishes armored signatures
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+	signer, err := check(keyring, bytes.NewReader(content), bytes.NewReader(signature))
+	if err != nil {
+		return &SignatureError{URL: url, Err: err}
+	}
+
+	log.Debugf("Signature of %s verified with key %X", url, signer.PrimaryKey.Fingerprint)
+
+	return nil
+}
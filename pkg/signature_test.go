@@ -0,0 +1,105 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestTrustedKeyringEmbedded : the hashicorp key is always trusted for terraform, even without keyring file,
+// and never for OpenTofu
+func TestTrustedKeyringEmbedded(t *testing.T) {
+	t.Setenv("SIMPLE_TFSWITCH_KEYRING", filepath.Join(t.TempDir(), "missing.asc"))
+	t.Setenv("SIMPLE_TFSWITCH_TOFU_KEYRING", filepath.Join(t.TempDir(), "missing.asc"))
+
+	keyring, err := pkg.TrustedKeyring(pkg.Terraform())
+	if err != nil {
+		t.Fatalf("Unable to load keyring: %v (unexpected)", err)
+	}
+
+	if len(keyring) != 1 {
+		t.Errorf("Expected only the hashicorp key, got %d keys (unexpected)", len(keyring))
+	}
+
+	if keyring, err := pkg.TrustedKeyring(pkg.OpenTofu()); err != nil || len(keyring) != 0 {
+		t.Errorf("Expected no key trusted for OpenTofu, got %d keys: %v (unexpected)", len(keyring), err)
+	}
+}
+
+// TestVerifySignatureScopedByProduct : a key trusted for one product does not validate the releases of the other
+func TestVerifySignatureScopedByProduct(t *testing.T) {
+	keyring := filepath.Join(t.TempDir(), "trusted-tofu-keys.asc")
+	if err := os.WriteFile(keyring, armoredPublicKey(t, testSigningKey(t)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIMPLE_TFSWITCH_KEYRING", filepath.Join(t.TempDir(), "missing.asc"))
+	t.Setenv("SIMPLE_TFSWITCH_TOFU_KEYRING", keyring)
+
+	content := []byte("0123  tofu_1.6.0_linux_amd64.zip\n")
+	signature := detachSign(t, testSigningKey(t), content)
+
+	if err := pkg.VerifySignature(pkg.OpenTofu(), "test", content, signature); err != nil {
+		t.Errorf("Signature of a trusted OpenTofu key rejected: %v (unexpected)", err)
+	}
+	if err := pkg.VerifySignature(pkg.Terraform(), "test", content, signature); !errors.Is(err, pkg.ErrChecksum) {
+		t.Errorf("Signature of an OpenTofu key accepted for terraform: %v (unexpected)", err)
+	}
+}
+
+// TestVerifySignature : only content signed by a trusted key is accepted
+func TestVerifySignature(t *testing.T) {
+	trustTestKey(t)
+	content := []byte("0123  terraform_1.0.0_linux_amd64.zip\n")
+	signature := detachSign(t, testSigningKey(t), content)
+
+	if err := pkg.VerifySignature(pkg.Terraform(), "test", content, signature); err != nil {
+		t.Errorf("Valid signature rejected: %v (unexpected)", err)
+	}
+
+	err := pkg.VerifySignature(pkg.Terraform(), "test", append(content, '\n'), signature)
+
+	var sigErr *pkg.SignatureError
+	if !errors.As(err, &sigErr) {
+		t.Errorf("Expected a signature error, got %v (unexpected)", err)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_KEYRING", filepath.Join(t.TempDir(), "missing.asc"))
+	if err := pkg.VerifySignature(pkg.Terraform(), "test", content, signature); !errors.As(err, &sigErr) {
+		t.Errorf("Signature of an untrusted key accepted: %v (unexpected)", err)
+	}
+}
+
+// TestAddTrustedKeys : keys added to the keyring file are trusted afterwards
+func TestAddTrustedKeys(t *testing.T) {
+	keyringPath := filepath.Join(t.TempDir(), "keys", "trusted-keys.asc")
+	t.Setenv("SIMPLE_TFSWITCH_KEYRING", keyringPath)
+
+	if err := pkg.AddTrustedKeys(pkg.Terraform(), []byte("not a key")); err == nil {
+		t.Error("Invalid key added (unexpected)")
+	}
+
+	if err := pkg.AddTrustedKeys(pkg.Terraform(), armoredPublicKey(t, testSigningKey(t))); err != nil {
+		t.Fatalf("Unable to add key: %v (unexpected)", err)
+	}
+
+	if _, err := os.Stat(keyringPath); err != nil {
+		t.Fatalf("Keyring file not created: %v (unexpected)", err)
+	}
+
+	content := []byte("signed content")
+	if err := pkg.VerifySignature(pkg.Terraform(), "test", content, detachSign(t, testSigningKey(t), content)); err != nil {
+		t.Errorf("Added key not trusted: %v (unexpected)", err)
+	}
+
+	keyring, err := pkg.TrustedKeyring(pkg.Terraform())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keyring) != 2 {
+		t.Errorf("Expected hashicorp and test keys, got %d keys (unexpected)", len(keyring))
+	}
+}
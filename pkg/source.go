@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errNotFound : the artifact does not exist on the source
+var errNotFound = errors.New("not found")
+
+// Source : where releases come from. Urls are the ones given in its releases,
+// the source is the only one to know how to open them.
+type Source interface {
+	// String : the location of the source, as configured
+	String() string
+	// Releases : all the releases of the source, in any order
+	Releases() ([]*Release, error)
+	// Release : describe the builds of one version
+	Release(version string) (*Release, error)
+	// Open : read an artifact of a release, a build or a SHA256SUMS file, missing ones are an errNotFound
+	Open(url string) (io.ReadCloser, error)
+}
+
+// NewSource : the source of the releases of product for a location, either
+// an http(s) mirror following the releases.hashicorp.com layout,
+// an http(s) mirror following the GitHub releases layout, with the url of its versions index as the index query parameter,
+// a file:// url to a copy of such a mirror,
+// or a plain directory holding the zips and SHA256SUMS files of the releases side by side.
+// Any other url is an ErrConfig.
+func NewSource(location string, product *Product) (Source, error) {
+	switch {
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, opError(ErrNetwork, "invalid release source "+location, err)
+		}
+		if index := u.Query().Get(indexQuery); index != "" {
+			u.RawQuery = ""
+
+			return &GitHubSource{HTTPSource: HTTPSource{URL: withSlash(u.String()), Product: product}, IndexURL: index}, nil
+		}
+
+		return &HTTPSource{URL: withSlash(location), Product: product}, nil
+	case strings.HasPrefix(location, "file://"):
+		return &FileSource{URL: withSlash(location), Product: product}, nil
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("%w: unsupported release source %s, expected an http(s) or file:// url, or a directory", ErrConfig, location)
+	default:
+		dir, err := filepath.Abs(location)
+		if err != nil {
+			return nil, opError(ErrFilesystem, "invalid release source "+location, err)
+		}
+
+		return &DirSource{Dir: dir, Product: product}, nil
+	}
+}
+
+// sizedReader : an artifact being read which knows its whole size
+type sizedReader struct {
+	io.ReadCloser
+	size int64
+}
+
+// artifactSize : the size of an artifact being read, -1 when unknown
+func artifactSize(r io.ReadCloser) int64 {
+	switch r := r.(type) {
+	case *sizedReader:
+		return r.size
+	case *os.File:
+		if info, err := r.Stat(); err == nil {
+			return info.Size()
+		}
+	}
+
+	return -1
+}
+
+// resumableSource : a source able to resume the download of an artifact
+type resumableSource interface {
+	// OpenFrom : read url from offset when the artifact still has the validator of the partial download,
+	// return where the content read starts, 0 when it is the whole artifact, and the validator
+	// to resume it later, "" when it cannot be resumed
+	OpenFrom(url string, offset int64, validator string) (io.ReadCloser, int64, string, error)
+}
+
+// isRemote : the source needs the network
+func isRemote(src Source) bool {
+	switch s := src.(type) {
+	case *HTTPSource, *GitHubSource:
+		return true
+	case *FileSource, *DirSource:
+		return false
+	case *FailoverSource:
+		for _, m := range s.Sources {
+			if isRemote(m) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return true
+	}
+}
+
+// readArtifact : the whole content of an artifact of src
+func readArtifact(src Source, url string) ([]byte, error) {
+	r, err := src.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, opError(ErrNetwork, "unable to read "+url, err)
+	}
+
+	return content, nil
+}
+
+// readJSON : decode the JSON document at url into v,
+// errNoIndex is returned when the document does not exist or is not JSON
+func readJSON(src Source, url string, v interface{}) error {
+	content, err := readArtifact(src, url)
+	if errors.Is(err, errNotFound) {
+		return errNoIndex
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		log.Debugf("Unable to decode %s: %v", url, err)
+
+		return errNoIndex
+	}
+
+	return nil
+}
+
+// fileURL : the file:// url of a local path
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// windows drive letter
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// fileURLPath : the local path of a file:// url
+func fileURLPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", opError(ErrFilesystem, "invalid file url "+rawURL, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("%w: not a file url: %s", ErrFilesystem, rawURL)
+	}
+
+	path := u.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		// windows drive letter
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path), nil
+}
+
+// openFileURL : open the local file of a file:// url
+func openFileURL(rawURL string) (io.ReadCloser, error) {
+	path, err := fileURLPath(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, opError(ErrFilesystem, "unable to open "+rawURL, errNotFound)
+	}
+	if err != nil {
+		return nil, opError(ErrFilesystem, "unable to open "+rawURL, err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FileSource : a copy of an http mirror on a local or network filesystem, as a file:// url,
+// described by its index.json or, when there is none, by its version folders
+type FileSource struct {
+	URL     string
+	Product *Product
+}
+
+func (s *FileSource) String() string {
+	return s.URL
+}
+
+// Releases : the releases from the index.json of the mirror, or from its version folders
+func (s *FileSource) Releases() ([]*Release, error) {
+	releases, err := indexReleases(s, s.URL)
+	if !errors.Is(err, errNoIndex) {
+		return releases, err
+	}
+
+	log.Debugf("No releases index on %s, listing the version folders", s.URL)
+	dir, err := fileURLPath(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, opError(ErrFilesystem, "unable to list "+s.URL, err)
+	}
+
+	versions := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+
+	return layoutReleases(s, s.URL, versions), nil
+}
+
+// Release : one version from its own index.json, or the default layout
+func (s *FileSource) Release(version string) (*Release, error) {
+	return versionRelease(s, s.URL, version)
+}
+
+// Open : open the file of url
+func (s *FileSource) Open(url string) (io.ReadCloser, error) {
+	return openFileURL(url)
+}
+
+// DirSource : a plain directory holding the release zips, with their SHA256SUMS and signature files,
+// all side by side as downloaded, such as terraform_1.2.3_linux_amd64.zip and terraform_1.2.3_SHA256SUMS
+type DirSource struct {
+	Dir     string
+	Product *Product
+}
+
+func (s *DirSource) String() string {
+	return s.Dir
+}
+
+// Releases : the versions of the zips found in the directory
+func (s *DirSource) Releases() ([]*Release, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, opError(ErrFilesystem, "unable to list "+s.Dir, err)
+	}
+
+	zipName := regexp.MustCompile(`^` + regexp.QuoteMeta(productOf(s).Name) + `_(.+)_[^_]+_[^_]+\.zip$`)
+
+	releases := []*Release{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		match := zipName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+
+		if r, err := s.Release(match[1]); err == nil {
+			releases = append(releases, r)
+		}
+	}
+
+	return releases, nil
+}
+
+// Release : one version, following the default file names in the directory
+func (s *DirSource) Release(version string) (*Release, error) {
+	if !ValidVersionFormat(version) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, version)
+	}
+
+	r := &Release{Version: version}
+	r.resolve(withSlash(fileURL(s.Dir)), productOf(s))
+
+	return r, nil
+}
+
+// Open : open the file of url
+func (s *DirSource) Open(url string) (io.ReadCloser, error) {
+	return openFileURL(url)
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	indexQuery = "index"
+	tagPrefix  = "v"
+)
+
+// GitHubSource : an http(s) mirror following the GitHub releases layout, as the OpenTofu releases,
+// the files of a version in a v<version> folder, described by a separate versions index
+type GitHubSource struct {
+	HTTPSource
+	// IndexURL : the versions index, {"versions": [{"id": "1.6.0"}, ...]} as get.opentofu.org/tofu/api.json
+	IndexURL string
+}
+
+// versionsIndex : the versions index of a GitHub releases mirror
+type versionsIndex struct {
+	Versions []struct {
+		ID string `json:"id"`
+	} `json:"versions"`
+}
+
+func (s *GitHubSource) String() string {
+	return s.URL + "?" + indexQuery + "=" + s.IndexURL
+}
+
+// Releases : the releases of the versions listed by the versions index
+func (s *GitHubSource) Releases() ([]*Release, error) {
+	index := &versionsIndex{}
+	err := readJSON(s, s.IndexURL, index)
+	if errors.Is(err, errNoIndex) {
+		return nil, fmt.Errorf("%w: cannot get list from versions index: %s", ErrNetwork, s.IndexURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*Release, 0, len(index.Versions))
+	for _, v := range index.Versions {
+		r, err := s.Release(v.ID)
+		if err != nil {
+			log.Debugf("Skipping release with invalid version %q: %v", v.ID, err)
+
+			continue
+		}
+		releases = append(releases, r)
+	}
+
+	return releases, nil
+}
+
+// Release : one version, following the default file names in its tag folder
+func (s *GitHubSource) Release(version string) (*Release, error) {
+	if !ValidVersionFormat(version) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, version)
+	}
+
+	r := &Release{Version: version}
+	r.resolve(s.URL+tagPrefix+version+"/", productOf(s))
+
+	return r, nil
+}
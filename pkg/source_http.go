@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPSource : an http(s) mirror following the releases.hashicorp.com layout,
+// described by its index.json or, when it serves none, by its HTML listing
+type HTTPSource struct {
+	URL     string
+	Product *Product
+}
+
+func (s *HTTPSource) String() string {
+	return s.URL
+}
+
+// Releases : the releases from the index.json of the mirror, or from the links of its HTML listing
+func (s *HTTPSource) Releases() ([]*Release, error) {
+	releases, err := indexReleases(s, s.URL)
+	if errors.Is(err, errNoIndex) {
+		log.Debugf("No releases index on %s, parsing the HTML listing", s.URL)
+		releases, err = s.htmlReleases()
+	}
+
+	return releases, err
+}
+
+// Release : one version from its own index.json, or the default layout when the mirror serves none
+func (s *HTTPSource) Release(version string) (*Release, error) {
+	return versionRelease(s, s.URL, version)
+}
+
+// Open : get url, a 404 or 403 answer is an errNotFound
+func (s *HTTPSource) Open(url string) (io.ReadCloser, error) {
+	r, _, _, err := s.OpenFrom(url, 0, "")
+
+	return r, err
+}
+
+// OpenFrom : get url from offset with a Range request validated by If-Range, the server sends the whole artifact
+// when it changed since validator, its ETag or Last-Modified date, was returned.
+// There is no validator when the server does not advertise Accept-Ranges.
+func (s *HTTPSource) OpenFrom(url string, offset int64, validator string) (io.ReadCloser, int64, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, "", opError(ErrNetwork, "invalid url "+url, err)
+	}
+	if offset > 0 && validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+
+	resp, err := HTTPClient().Do(req)
+	if err != nil {
+		return nil, 0, "", opError(ErrNetwork, "unable to download from "+url, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &sizedReader{ReadCloser: resp.Body, size: resp.ContentLength}, 0, rangeValidator(resp), nil
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			resp.Body.Close()
+			log.Debugf("Unexpected range %q for %s, downloading it all", resp.Header.Get("Content-Range"), url)
+
+			return s.OpenFrom(url, 0, "")
+		}
+
+		return &sizedReader{ReadCloser: resp.Body, size: size}, start, validator, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		log.Debugf("Partial download of %s is longer than the artifact, downloading it all", url)
+
+		return s.OpenFrom(url, 0, "")
+	case http.StatusNotFound, http.StatusForbidden:
+		resp.Body.Close()
+
+		return nil, 0, "", opError(ErrNetwork, "unable to download from "+url, fmt.Errorf("%w (%s)", errNotFound, resp.Status))
+	default:
+		resp.Body.Close()
+
+		return nil, 0, "", fmt.Errorf("%w: unable to download from %s: %s", ErrNetwork, url, resp.Status)
+	}
+}
+
+// rangeValidator : what identifies the version of an artifact to resume its download, its strong ETag
+// or its Last-Modified date, "" when the server does not support ranges
+func rangeValidator(resp *http.Response) string {
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return ""
+	}
+	// weak ETags cannot be used in If-Range
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
+
+// parseContentRange : the first byte and the whole size of a "bytes first-last/size" Content-Range,
+// the size is -1 when unknown
+func parseContentRange(value string) (int64, int64, bool) {
+	var first, last int64
+	var size string
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/%s", &first, &last, &size); err != nil {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return first, -1, true
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+
+	return first, total, err == nil
+}
+
+// htmlReleases : get the releases from the links of the HTML listing of the mirror
+func (s *HTTPSource) htmlReleases() ([]*Release, error) {
+	body, err := readArtifact(s, s.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// links to version folders, either relative (1.2.3/) or absolute (/terraform/1.2.3/)
+	versionLink := regexp.MustCompile(`href="(?:[^"]*/)?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)/?"`)
+
+	versions := []string{}
+	for _, match := range versionLink.FindAllStringSubmatch(string(body), -1) {
+		versions = append(versions, match[1])
+	}
+
+	releases := layoutReleases(s, s.URL, versions)
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("%w: cannot get list from mirror: %s", ErrNetwork, s.URL)
+	}
+
+	return releases, nil
+}
+
+// indexReleases : get the releases from the index.json at the root of a mirror
+func indexReleases(src Source, mirrorURL string) ([]*Release, error) {
+	index := &productIndex{}
+	if err := readJSON(src, mirrorURL+indexFile, index); err != nil {
+		return nil, err
+	}
+
+	releases := make([]*Release, 0, len(index.Versions))
+	for version, r := range index.Versions {
+		if r.Version == "" {
+			r.Version = version
+		}
+		if _, err := semver.NewVersion(r.Version); err != nil {
+			log.Debugf("Skipping release with invalid version %q: %v", r.Version, err)
+
+			continue
+		}
+		r.resolve(mirrorURL+r.Version+"/", productOf(src))
+		releases = append(releases, r)
+	}
+
+	return releases, nil
+}
+
+// versionRelease : describe one version of a mirror from its own index.json,
+// or assume the default layout for mirrors not serving one
+func versionRelease(src Source, mirrorURL string, version string) (*Release, error) {
+	release := &Release{}
+	err := readJSON(src, mirrorURL+version+"/"+indexFile, release)
+	if errors.Is(err, errNoIndex) {
+		log.Debugf("No release index for %s on %s, using the default layout", version, mirrorURL)
+		release = &Release{Version: version}
+	} else if err != nil {
+		return nil, err
+	}
+	release.resolve(mirrorURL+version+"/", productOf(src))
+
+	return release, nil
+}
+
+// layoutReleases : the releases of the default layout for versions of a mirror, invalid and duplicate versions skipped
+func layoutReleases(src Source, mirrorURL string, versions []string) []*Release {
+	seen := map[string]bool{}
+	releases := []*Release{}
+	for _, version := range versions {
+		if seen[version] {
+			continue
+		}
+		if _, err := semver.NewVersion(version); err != nil {
+			continue
+		}
+		seen[version] = true
+
+		r := &Release{Version: version}
+		r.resolve(mirrorURL+version+"/", productOf(src))
+		releases = append(releases, r)
+	}
+
+	return releases
+}
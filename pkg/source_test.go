@@ -0,0 +1,86 @@
+package pkg_test
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestNewSource : the kind of source depends on the location, a path is a directory of zips
+func TestNewSource(t *testing.T) {
+	dir, err := filepath.Abs("zips")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terraform, tofu := pkg.Terraform(), pkg.OpenTofu()
+	for _, tt := range []struct {
+		location string
+		product  *pkg.Product
+		expected pkg.Source
+	}{
+		{"https://releases.hashicorp.com/terraform", terraform, &pkg.HTTPSource{URL: "https://releases.hashicorp.com/terraform/", Product: terraform}},
+		{"file:///srv/mirror/terraform/", terraform, &pkg.FileSource{URL: "file:///srv/mirror/terraform/", Product: terraform}},
+		{"zips", tofu, &pkg.DirSource{Dir: dir, Product: tofu}},
+		{
+			"https://github.com/opentofu/opentofu/releases/download?index=https://get.opentofu.org/tofu/api.json", tofu,
+			&pkg.GitHubSource{
+				HTTPSource: pkg.HTTPSource{URL: "https://github.com/opentofu/opentofu/releases/download/", Product: tofu},
+				IndexURL:   "https://get.opentofu.org/tofu/api.json",
+			},
+		},
+	} {
+		src, err := pkg.NewSource(tt.location, tt.product)
+		if err != nil {
+			t.Fatalf("Unable to create source for %s: %v (unexpected)", tt.location, err)
+		}
+		if !reflect.DeepEqual(src, tt.expected) {
+			t.Errorf("Expected %#v for %s, got %#v (unexpected)", tt.expected, tt.location, src)
+		}
+	}
+
+	for _, location := range []string{"s3://bucket/terraform", "ftp://mirror/terraform"} {
+		if _, err := pkg.NewSource(location, terraform); !errors.Is(err, pkg.ErrConfig) {
+			t.Errorf("Expected %s to be rejected as invalid configuration, got %v (unexpected)", location, err)
+		}
+	}
+}
+
+// TestInstallFromLocalSources : list and install from a copy of the mirror and from a directory of zips,
+// without the network
+func TestInstallFromLocalSources(t *testing.T) {
+	srv := newReleaseServer(t, "1.2.3", "1.3.0")
+	srv.Close()
+
+	mirror := t.TempDir()
+	srv.writeFiles(t, mirror, false)
+	zips := t.TempDir()
+	srv.writeFiles(t, zips, true)
+
+	for _, location := range []string{(&url.URL{Scheme: "file", Path: filepath.ToSlash(mirror)}).String(), zips} {
+		t.Setenv("SNAP_USER_COMMON", t.TempDir())
+		t.Setenv("SIMPLE_TFSWITCH_OFFLINE", "1")
+
+		src, err := pkg.NewSource(location, pkg.Terraform())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions, err := pkg.GetTFList(src, false)
+		if expected := []string{"1.3.0", "1.2.3"}; err != nil || !reflect.DeepEqual(versions, expected) {
+			t.Errorf("Expected versions %v from %s, got %v, %v (unexpected)", expected, location, versions, err)
+		}
+
+		installed, err := pkg.InstallVersion("~> 1.2.0", src)
+		if err != nil {
+			t.Fatalf("Unable to install from %s: %v (unexpected)", location, err)
+		}
+		if filepath.Base(installed) != pkg.ConvertExecutableExt("terraform_1.2.3") {
+			t.Errorf("Unexpected installed file %v from %s", installed, location)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// StrategyNewest : the newest version matching the constraint, the default
+	StrategyNewest = "newest"
+	// StrategyOldest : the oldest version matching the constraint, as minimum version selection,
+	// so that a new release never changes the version of an open-ended constraint
+	StrategyOldest = "oldest"
+	// StrategyOldestMinor : the newest patch of the oldest minor version matching the constraint,
+	// bug fixes are picked up but never a new minor version
+	StrategyOldestMinor = "oldest-minor"
+)
+
+// ResolutionStrategy : how a constraint is resolved to a version, set by the strategy setting
+// or SIMPLE_TFSWITCH_STRATEGY, newest by default
+func ResolutionStrategy() string {
+	s := configSetting(settingStrategy)
+	switch s.Value {
+	case StrategyNewest, StrategyOldest, StrategyOldestMinor:
+		return s.Value
+	default:
+		log.Warnf("Invalid %s %q from %s, using %s", s.Name, s.Value, s.Origin, StrategyNewest)
+
+		return StrategyNewest
+	}
+}
+
+// selectVersion : the version chosen by strategy among versions, the versions matching a constraint
+// sorted from the newest, nil when there is none
+func selectVersion(strategy string, versions []*semver.Version) *semver.Version {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	oldest := versions[len(versions)-1]
+	switch strategy {
+	case StrategyOldest:
+		return oldest
+	case StrategyOldestMinor:
+		for _, v := range versions {
+			if v.Major() == oldest.Major() && v.Minor() == oldest.Minor() {
+				return v
+			}
+		}
+	}
+
+	return versions[0]
+}
@@ -0,0 +1,50 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// TestResolutionStrategy : each strategy chooses its version among those matching the constraint
+func TestResolutionStrategy(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.4.9", "1.5.0", "1.5.7", "1.6.0", "1.6.2", "1.7.0-beta1")
+
+	for _, tt := range []struct {
+		strategy   string
+		constraint string
+		expected   string
+	}{
+		{"", ">= 1.5.0", "1.6.2"},
+		{pkg.StrategyNewest, "~> 1.5.0", "1.5.7"},
+		{pkg.StrategyOldest, ">= 1.5.0", "1.5.0"},
+		{pkg.StrategyOldest, "> 1.5.0, < 1.7.0", "1.5.7"},
+		{pkg.StrategyOldestMinor, ">= 1.5.0", "1.5.7"},
+		{pkg.StrategyOldestMinor, ">= 1.5.8", "1.6.2"},
+		{"unknown", ">= 1.5.0", "1.6.2"},
+		// an exact version is never resolved
+		{pkg.StrategyOldest, "1.6.0", "1.6.0"},
+	} {
+		t.Setenv("SIMPLE_TFSWITCH_STRATEGY", tt.strategy)
+		tfversion, err := pkg.ResolveVersion(tt.constraint, srv.source())
+		if err != nil || tfversion != tt.expected {
+			t.Errorf("Expected %s for %q with the %q strategy, got %s, %v (unexpected)", tt.expected, tt.constraint, tt.strategy, tfversion, err)
+		}
+	}
+}
+
+// TestResolutionPreReleases : pre-releases are candidates unless the pre_releases setting is off
+func TestResolutionPreReleases(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	srv := newReleaseServer(t, "1.6.2", "1.7.0-beta1")
+
+	if tfversion, err := pkg.ResolveVersion(">= 1.7.0-alpha", srv.source()); err != nil || tfversion != "1.7.0-beta1" {
+		t.Errorf("Expected 1.7.0-beta1, got %s, %v (unexpected)", tfversion, err)
+	}
+
+	t.Setenv("SIMPLE_TFSWITCH_PRE_RELEASES", "false")
+	if tfversion, err := pkg.ResolveVersion(">= 1.6.0-alpha", srv.source()); err != nil || tfversion != "1.6.2" {
+		t.Errorf("Expected 1.6.2 without pre-releases, got %s, %v (unexpected)", tfversion, err)
+	}
+}
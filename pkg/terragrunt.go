@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+const (
+	terragruntFile              = "terragrunt.hcl"
+	terragruntCache             = ".terragrunt-cache"
+	terragruntVersionConstraint = "terraform_version_constraint"
+	maxTerragruntIncludes       = 10
+)
+
+// errTerragruntUnsupported : the configuration needs terragrunt itself to be evaluated,
+// a function or a file only terragrunt knows about
+var errTerragruntUnsupported = errors.New("needs terragrunt to be evaluated")
+
+// terragruntDir : the directory of the terragrunt.hcl in charge of dir, the directory holding
+// the .terragrunt-cache when dir is one of the working copies terragrunt runs terraform in, dir otherwise
+func terragruntDir(dir string) string {
+	for d := dir; ; {
+		if filepath.Base(d) == terragruntCache {
+			return filepath.Dir(d)
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// findTerragruntConstraint : the terraform_version_constraint of the terragrunt.hcl in charge of dir,
+// or else of the configurations it includes, nil when there is none. A configuration which only terragrunt
+// can evaluate is ignored with a warning, the required_version of the terraform files applying alone.
+func findTerragruntConstraint(dir string) (*RequiredVersion, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(terragruntDir(dir), terragruntFile)
+	if !CheckFileExist(path) {
+		return nil, nil
+	}
+
+	req, err := readTerragruntConstraint(path, path, 0)
+	if errors.Is(err, errTerragruntUnsupported) {
+		log.Warnf("Ignoring the %s of %s, using the %s of the terraform files: %v", terragruntVersionConstraint, path, requiredVersion, err)
+
+		return nil, nil
+	}
+
+	return req, err
+}
+
+// readTerragruntConstraint : the terraform_version_constraint of the terragrunt configuration at path,
+// or else of the configurations it includes, the functions being evaluated for child, the configuration being resolved.
+// Only the constraint, the include paths and the locals they use are evaluated.
+func readTerragruntConstraint(path string, child string, depth int) (*RequiredVersion, error) {
+	if depth > maxTerragruntIncludes {
+		return nil, fmt.Errorf("%w: too many nested includes in %s", ErrConfig, child)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, opError(ErrFilesystem, "unable to read "+path, err)
+	}
+	file, diags := hclsyntax.ParseConfig(content, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, opError(ErrConfig, "unable to parse "+path, diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil
+	}
+
+	ctx := terragruntEvalContext(path, child)
+	leftOut := evalTerragruntLocals(body, ctx)
+
+	if attr, ok := body.Attributes[terragruntVersionConstraint]; ok {
+		var constraint string
+		if diags := gohcl.DecodeExpression(attr.Expr, ctx, &constraint); diags.HasErrors() {
+			return nil, terragruntEvalError("the "+terragruntVersionConstraint+" of "+path, attr.Expr, leftOut, diags)
+		}
+
+		return &RequiredVersion{File: path, Constraint: constraint}, nil
+	}
+
+	for _, block := range body.Blocks {
+		attr, ok := block.Body.Attributes["path"]
+		if block.Type != "include" || !ok {
+			continue
+		}
+
+		var include string
+		if diags := gohcl.DecodeExpression(attr.Expr, ctx, &include); diags.HasErrors() {
+			return nil, terragruntEvalError("an include path of "+path, attr.Expr, leftOut, diags)
+		}
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		include = filepath.Clean(include)
+		log.Debugf("Following the include of %s from %s", include, path)
+
+		req, err := readTerragruntConstraint(include, child, depth+1)
+		if err != nil || req != nil {
+			return req, err
+		}
+	}
+
+	return nil, nil
+}
+
+// terragruntEvalError : the error of an expression which cannot be evaluated, an errTerragruntUnsupported
+// when it calls a function only terragrunt knows, or a function failing as find_in_parent_folders for a missing file,
+// or uses a local left out for the same reasons, an ErrConfig otherwise
+func terragruntEvalError(what string, expr hcl.Expression, leftOut map[string]bool, diags hcl.Diagnostics) error {
+	unsupported := false
+	for _, d := range diags {
+		if d.Summary == "Call to unknown function" || d.Summary == "Error in function call" {
+			unsupported = true
+		}
+	}
+	for _, traversal := range expr.Variables() {
+		if len(traversal) < 2 || traversal.RootName() != "local" {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok && leftOut[attr.Name] {
+			unsupported = true
+		}
+	}
+
+	if unsupported {
+		return fmt.Errorf("unable to evaluate %s, it %w: %s", what, errTerragruntUnsupported, diags.Error())
+	}
+
+	return opError(ErrConfig, "unable to evaluate "+what, diags)
+}
+
+// evalTerragruntLocals : add to ctx the locals of body which can be evaluated,
+// in as many passes as they depend on each other, the others are left out and returned
+func evalTerragruntLocals(body *hclsyntax.Body, ctx *hcl.EvalContext) map[string]bool {
+	attrs := hclsyntax.Attributes{}
+	for _, block := range body.Blocks {
+		if block.Type == "locals" {
+			for name, attr := range block.Body.Attributes {
+				attrs[name] = attr
+			}
+		}
+	}
+
+	locals := map[string]cty.Value{}
+	for progress := true; progress; {
+		progress = false
+		for name, attr := range attrs {
+			if _, ok := locals[name]; ok {
+				continue
+			}
+			value, diags := attr.Expr.Value(ctx)
+			if diags.HasErrors() {
+				continue
+			}
+			locals[name] = value
+			ctx.Variables["local"] = cty.ObjectVal(locals)
+			progress = true
+		}
+	}
+
+	leftOut := map[string]bool{}
+	for name := range attrs {
+		if _, ok := locals[name]; !ok {
+			leftOut[name] = true
+		}
+	}
+
+	return leftOut
+}
+
+// terragruntEvalContext : the terragrunt functions helping to locate the configurations, for the configuration
+// at path being read on behalf of child
+func terragruntEvalContext(path string, child string) *hcl.EvalContext {
+	childDir := filepath.Dir(child)
+	relative, err := filepath.Rel(filepath.Dir(path), childDir)
+	if err != nil {
+		relative = "."
+	}
+
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{"local": cty.EmptyObjectVal},
+		Functions: map[string]function.Function{
+			"find_in_parent_folders": function.New(&function.Spec{
+				VarParam: &function.Parameter{Name: "args", Type: cty.String},
+				Type:     function.StaticReturnType(cty.String),
+				Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+					name := terragruntFile
+					if len(args) > 0 {
+						name = args[0].AsString()
+					}
+					if found := findInParentFolders(childDir, name); found != "" {
+						return cty.StringVal(found), nil
+					}
+					if len(args) > 1 {
+						return args[1], nil
+					}
+
+					return cty.NilVal, fmt.Errorf("no %s found in the parent folders of %s", name, childDir)
+				},
+			}),
+			"get_terragrunt_dir":        stringFunction(childDir),
+			"get_parent_terragrunt_dir": stringFunction(filepath.Dir(path)),
+			"get_repo_root":             stringFunction(repositoryRoot(childDir)),
+			"path_relative_to_include":  stringFunction(filepath.ToSlash(relative)),
+		},
+	}
+}
+
+// stringFunction : a function without parameters returning value
+func stringFunction(value string) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(_ []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// findInParentFolders : the closest file called name in the parents of dir, "" if none
+func findInParentFolders(dir string, name string) string {
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		if path := filepath.Join(d, name); CheckFileExist(path) {
+			return path
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// repositoryRoot : the root of the git repository of dir, dir itself when not in a repository
+func repositoryRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if isRepositoryRoot(d) {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return dir
+		}
+	}
+}
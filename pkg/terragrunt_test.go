@@ -0,0 +1,136 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// newTerragruntRepository : a terragrunt live repository whose root.hcl sets the terraform_version_constraint
+// through its locals, included by live/prod/vpc, return the repository and the vpc directory
+func newTerragruntRepository(t *testing.T) (string, string) {
+	t.Helper()
+
+	repo := t.TempDir()
+	vpc := filepath.Join(repo, "live", "prod", "vpc")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(vpc, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(repo, "root.hcl"), `
+locals {
+  constraint = "~> ${local.minor}.0"
+  minor      = "1.5"
+  account    = read_terragrunt_config("account.hcl")
+}
+
+terraform_version_constraint = local.constraint
+
+inputs = {
+  account = local.account
+}
+`)
+	writeFile(t, filepath.Join(vpc, "terragrunt.hcl"), `
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+terraform {
+  source = "git::https://example.com/modules.git//vpc"
+}
+`)
+
+	return repo, vpc
+}
+
+// TestTerragruntConstraint : the terraform_version_constraint is read from the included configuration,
+// unless the terragrunt.hcl sets its own
+func TestTerragruntConstraint(t *testing.T) {
+	repo, vpc := newTerragruntRepository(t)
+	root := filepath.Join(repo, "root.hcl")
+
+	expectRequirement(t, vpc, "~> 1.5.0", "terraform_version_constraint of "+root+" (~> 1.5.0)")
+
+	// an unlabeled include of the terragrunt.hcl of a parent folder, with a relative path
+	writeFile(t, filepath.Join(repo, "live", "terragrunt.hcl"), `terraform_version_constraint = ">= 1.4"`)
+	writeFile(t, filepath.Join(vpc, "terragrunt.hcl"), `include { path = "${get_terragrunt_dir()}/../../terragrunt.hcl" }`)
+	expectRequirement(t, vpc, ">= 1.4", "terraform_version_constraint of "+filepath.Join(repo, "live", "terragrunt.hcl")+" (>= 1.4)")
+
+	writeFile(t, filepath.Join(vpc, "terragrunt.hcl"), `
+include {
+  path = find_in_parent_folders()
+}
+
+terraform_version_constraint = "~> 1.6.0"
+`)
+	expectRequirement(t, vpc, "~> 1.6.0", "terraform_version_constraint of "+filepath.Join(vpc, "terragrunt.hcl")+" (~> 1.6.0)")
+}
+
+// TestTerragruntCache : terraform run by terragrunt in its working copy uses the constraint of the terragrunt.hcl
+// owning the cache, along with the required_version of the module
+func TestTerragruntCache(t *testing.T) {
+	repo, vpc := newTerragruntRepository(t)
+	root := filepath.Join(repo, "root.hcl")
+
+	module := filepath.Join(vpc, ".terragrunt-cache", "Xk2e", "9jYm", "vpc")
+	if err := os.MkdirAll(module, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(module, "main.tf")
+	writeFile(t, main, "terraform {\n  required_version = \">= 1.3\"\n}\n")
+
+	expectRequirement(t, module, "~> 1.5.0, >= 1.3",
+		"terraform_version_constraint of "+root+" (~> 1.5.0), required_version of "+main+" (>= 1.3)")
+
+	// the working copies are not configurations of their own
+	requirements, err := pkg.ScanRequirements(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requirements) != 1 || requirements[vpc] == nil || requirements[vpc].Constraint != "~> 1.5.0" {
+		t.Errorf("Expected only %s to require ~> 1.5.0, got %v (unexpected)", vpc, requirements)
+	}
+}
+
+// TestTerragruntInvalid : an invalid configuration is a configuration error, not a silently ignored constraint
+func TestTerragruntInvalid(t *testing.T) {
+	_, vpc := newTerragruntRepository(t)
+
+	for _, content := range []string{`terraform_version_constraint = local.unknown`, `terraform_version_constraint = `} {
+		writeFile(t, filepath.Join(vpc, "terragrunt.hcl"), content)
+		if _, err := pkg.FindVersionRequirement(vpc); !errors.Is(err, pkg.ErrConfig) {
+			t.Errorf("Expected a configuration error for %q, got %v (unexpected)", content, err)
+		}
+	}
+}
+
+// TestTerragruntUnsupported : a configuration only terragrunt can evaluate is ignored,
+// the required_version of the terraform files applying alone
+func TestTerragruntUnsupported(t *testing.T) {
+	_, vpc := newTerragruntRepository(t)
+	main := filepath.Join(vpc, "main.tf")
+	writeFile(t, main, "terraform {\n  required_version = \">= 1.3\"\n}\n")
+
+	for _, content := range []string{
+		`terraform_version_constraint = get_env("TF_VERSION_CONSTRAINT", "~> 1.5.0")`,
+		`include { path = "${get_path_to_repo_root()}/root.hcl" }`,
+		`include { path = "${path_relative_from_include()}/root.hcl" }`,
+		`include { path = find_in_parent_folders("missing.hcl") }`,
+		`
+locals {
+  constraint = get_env("TF_VERSION_CONSTRAINT", "~> 1.5.0")
+}
+
+terraform_version_constraint = local.constraint
+`,
+	} {
+		writeFile(t, filepath.Join(vpc, "terragrunt.hcl"), content)
+		expectRequirement(t, vpc, ">= 1.3", "required_version of "+main+" (>= 1.3)")
+	}
+}
@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg/logger"
+)
+
+const (
+	versionEnv      = "TFSWITCH_VERSION"
+	toolVersions    = ".tool-versions"
+	requiredVersion = "required_version"
+
+	latestRegexPrefix = "latest:"
+)
+
+// errNoVersionFound : none of the sources gives a version to use
+var errNoVersionFound = errors.New("no required_versions found")
+
+// VersionRequirement : an exact version or a constraint, along with where it was found
+type VersionRequirement struct {
+	Constraint string
+	Source     string
+
+	// RequiredVersions : the required_version constraints combined in Constraint, when coming from terraform files,
+	// along with the terraform_version_constraint of terragrunt
+	RequiredVersions []RequiredVersion
+	// Lock : the version locked by the lock file of the repository, Constraint being its exact version
+	Lock *LockedVersion
+}
+
+// FindVersionRequirement : find the version to use for dir, by order of precedence:
+// - the TFSWITCH_VERSION environment variable
+// - a .terraform-version (.opentofu-version for tofu) or .tool-versions file in dir or its parents, up to the repository root
+// - the terraform_version_constraint of the terragrunt.hcl of dir, combined with the required_version of the terraform files in dir
+// The version locked in the lock file of the repository is used instead, as long as it satisfies this requirement.
+func FindVersionRequirement(dir string) (*VersionRequirement, error) {
+	req, err := findVersionRequirement(dir)
+	if err != nil && !errors.Is(err, errNoVersionFound) {
+		return nil, err
+	}
+
+	// the TFSWITCH_VERSION override wins over the lock file, as over everything the repository declares
+	if req == nil || req.Source != versionEnv {
+		locked, errLock := applyLock(dir, req)
+		if errLock != nil {
+			return nil, errLock
+		}
+		if locked == nil {
+			return nil, err
+		}
+		req = locked
+	}
+
+	log.WithFields(log.Fields{
+		logger.FieldConstraint: req.Constraint,
+		logger.FieldSource:     req.Source,
+	}).Debugf("Using version %q from %s", req.Constraint, req.Source)
+
+	return req, nil
+}
+
+func findVersionRequirement(dir string) (*VersionRequirement, error) {
+	if version := strings.TrimSpace(os.Getenv(versionEnv)); version != "" {
+		return &VersionRequirement{Constraint: version, Source: versionEnv}, nil
+	}
+
+	return repositoryRequirement(dir)
+}
+
+// repositoryRequirement : the requirement of dir as declared by the repository, its lock file and
+// the TFSWITCH_VERSION override aside, errNoVersionFound when there is none
+func repositoryRequirement(dir string) (*VersionRequirement, error) {
+	req, err := findVersionFile(dir, ProductFor(dir))
+	if err != nil || req != nil {
+		return req, err
+	}
+
+	req, err = moduleRequirement(dir)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errNoVersionFound
+	}
+
+	return req, nil
+}
+
+// moduleRequirement : the requirement of the configuration of dir, the terraform_version_constraint
+// of the terragrunt.hcl in charge of dir combined with the required_version of the terraform files, nil if none
+func moduleRequirement(dir string) (*VersionRequirement, error) {
+	required, err := LoadRequiredVersions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	terragrunt, err := findTerragruntConstraint(dir)
+	if err != nil {
+		return nil, err
+	}
+	if terragrunt == nil {
+		if len(required) == 0 {
+			return nil, nil
+		}
+
+		return requiredVersionsRequirement(required), nil
+	}
+
+	req := &VersionRequirement{
+		Source:           terragruntVersionConstraint + " of " + terragrunt.String(),
+		RequiredVersions: append([]RequiredVersion{*terragrunt}, required...),
+	}
+	req.Constraint = joinRequiredVersions(req.RequiredVersions)
+	if len(required) > 0 {
+		req.Source += ", " + requiredVersion + " of " + joinRequiredVersionSources(required)
+	}
+
+	return req, nil
+}
+
+// requiredVersionsRequirement : the requirement combining all the required_version of a module
+func requiredVersionsRequirement(required []RequiredVersion) *VersionRequirement {
+	return &VersionRequirement{
+		Constraint:       joinRequiredVersions(required),
+		Source:           requiredVersion + " of " + joinRequiredVersionSources(required),
+		RequiredVersions: required,
+	}
+}
+
+// findVersionFile : look for a version file of product from dir up to the repository root,
+// the closest one wins and .terraform-version wins over .tool-versions in the same directory
+func findVersionFile(dir string, product *Product) (*VersionRequirement, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		req, err := readTerraformVersion(filepath.Join(dir, product.VersionFile))
+		if err != nil || req != nil {
+			return req, err
+		}
+
+		req, err = readToolVersions(filepath.Join(dir, toolVersions), product)
+		if err != nil || req != nil {
+			return req, err
+		}
+
+		parent := filepath.Dir(dir)
+		if isRepositoryRoot(dir) || parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// readTerraformVersion : read a tfenv .terraform-version file, or the .opentofu-version of tofuenv,
+// the first non comment line holds the version
+func readTerraformVersion(path string) (*VersionRequirement, error) {
+	lines, err := readVersionFileLines(path)
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+
+	return &VersionRequirement{Constraint: versionFileConstraint(lines[0]), Source: path}, nil
+}
+
+// readToolVersions : read an asdf/mise .tool-versions file, the first version of the line of product is used
+func readToolVersions(path string, product *Product) (*VersionRequirement, error) {
+	lines, err := readVersionFileLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != product.ToolVersionsPlugin {
+			continue
+		}
+
+		return &VersionRequirement{Constraint: versionFileConstraint(fields[1]), Source: path}, nil
+	}
+
+	return nil, nil
+}
+
+// readVersionFileLines : the non empty lines of a version file without comments, nothing if it does not exist
+func readVersionFileLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, opError(ErrFilesystem, "unable to read "+path, err)
+	}
+
+	return lines, nil
+}
+
+// versionFileConstraint : translate the version of a version file to a constraint,
+// the tfenv "latest" keyword being any version, "latest:<regex>" is kept as is, see versionMatcher
+func versionFileConstraint(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	if version == "latest" {
+		return ">= 0.0.0"
+	}
+
+	return version
+}
+
+// versionMatcher : whether a version matches constraint, a semver constraint or the tfenv "latest:<regex>",
+// the newest version matching the regex as tfenv does
+func versionMatcher(constraint string) (func(*semver.Version) bool, error) {
+	if isLatestRegex(constraint) {
+		re, err := regexp.Compile(strings.TrimPrefix(constraint, latestRegexPrefix))
+		if err != nil {
+			return nil, opError(ErrInvalidVersion, fmt.Sprintf("error parsing the regex of %q", constraint), err)
+		}
+
+		return func(v *semver.Version) bool {
+			return re.MatchString(v.String())
+		}, nil
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, opError(ErrInvalidVersion, fmt.Sprintf("error parsing constraint %q, please check constraint syntax on terraform file", constraint), err)
+	}
+
+	return c.Check, nil
+}
+
+// isLatestRegex : the constraint is a tfenv "latest:<regex>", always resolved to the newest version matching
+func isLatestRegex(constraint string) bool {
+	return strings.HasPrefix(constraint, latestRegexPrefix)
+}
+
+// isRepositoryRoot : dir is the root of a git repository
+func isRepositoryRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+
+	return err == nil
+}
@@ -0,0 +1,130 @@
+package pkg_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/terraform-tools/simple-tfswitch/pkg"
+)
+
+// newRepository : create a git repository with a module in envs/prod, return the repository and the module path
+func newRepository(t *testing.T) (string, string) {
+	t.Helper()
+
+	repo := t.TempDir()
+	module := filepath.Join(repo, "envs", "prod")
+	createDirIfNotExist(filepath.Join(repo, ".git"))
+	createDirIfNotExist(module)
+	writeFile(t, filepath.Join(module, "main.tf"), "terraform {\n  required_version = \"~> 1.2.0\"\n}\n")
+
+	return repo, module
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectRequirement(t *testing.T, dir string, constraint string, source string) {
+	t.Helper()
+
+	req, err := pkg.FindVersionRequirement(dir)
+	if err != nil {
+		t.Fatalf("Unable to find version: %v (unexpected)", err)
+	}
+
+	if req.Constraint != constraint || req.Source != source {
+		t.Errorf("Expected %q from %s, got %q from %s (unexpected)", constraint, source, req.Constraint, req.Source)
+	}
+}
+
+// TestFindVersionRequirement : env override, then version files, then required_version
+func TestFindVersionRequirement(t *testing.T) {
+	repo, module := newRepository(t)
+	t.Setenv("TFSWITCH_VERSION", "")
+
+	expectRequirement(t, module, "~> 1.2.0", "required_version of "+filepath.Join(module, "main.tf")+" (~> 1.2.0)")
+
+	toolVersions := filepath.Join(repo, ".tool-versions")
+	writeFile(t, toolVersions, "golang 1.19.3\nterraform 1.2.4 1.1.0 # pinned\n")
+	expectRequirement(t, module, "1.2.4", toolVersions)
+
+	terraformVersion := filepath.Join(repo, ".terraform-version")
+	writeFile(t, terraformVersion, "# tfenv\n1.2.5\n")
+	expectRequirement(t, module, "1.2.5", terraformVersion)
+
+	closest := filepath.Join(module, ".tool-versions")
+	writeFile(t, closest, "terraform 1.2.6\n")
+	expectRequirement(t, module, "1.2.6", closest)
+
+	t.Setenv("TFSWITCH_VERSION", "1.2.7")
+	expectRequirement(t, module, "1.2.7", "TFSWITCH_VERSION")
+}
+
+// TestFindVersionRequirementRepositoryRoot : version files above the repository root are ignored
+func TestFindVersionRequirementRepositoryRoot(t *testing.T) {
+	parent := t.TempDir()
+	writeFile(t, filepath.Join(parent, ".terraform-version"), "0.12.31\n")
+
+	repo := filepath.Join(parent, "repo")
+	createDirIfNotExist(filepath.Join(repo, ".git"))
+	t.Setenv("TFSWITCH_VERSION", "")
+
+	if _, err := pkg.FindVersionRequirement(repo); err == nil {
+		t.Error("Version file outside of the repository used (unexpected)")
+	}
+
+	writeFile(t, filepath.Join(repo, ".terraform-version"), "latest\n")
+	expectRequirement(t, repo, ">= 0.0.0", filepath.Join(repo, ".terraform-version"))
+}
+
+// TestScanRequirementsVersionFileAbove : a configuration gets the version file found above it,
+// as when running in its directory, a directory declaring nothing is left out
+func TestScanRequirementsVersionFileAbove(t *testing.T) {
+	repo, module := newRepository(t)
+	writeFile(t, filepath.Join(repo, ".terraform-version"), "1.2.3\n")
+
+	requirements, err := pkg.ScanRequirements(filepath.Join(repo, "envs"))
+	if err != nil {
+		t.Fatalf("Unable to scan %s: %v (unexpected)", repo, err)
+	}
+	if len(requirements) != 1 {
+		t.Fatalf("Expected only the requirement of %s, got %v (unexpected)", module, requirements)
+	}
+
+	expected, err := pkg.FindVersionRequirement(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := requirements[module]; req == nil || req.Constraint != "1.2.3" || req.Source != expected.Source {
+		t.Errorf("Expected %+v for %s, got %+v (unexpected)", expected, module, req)
+	}
+}
+
+// TestLatestRegex : a tfenv latest:<regex> resolves to the newest version matching the regex, never to a wider one
+func TestLatestRegex(t *testing.T) {
+	t.Setenv("SNAP_USER_COMMON", t.TempDir())
+	t.Setenv("TFSWITCH_VERSION", "")
+	srv := newReleaseServer(t, "0.12.30", "0.12.31", "0.13.7", "1.5.7")
+	repo, module := newRepository(t)
+	path := filepath.Join(repo, ".terraform-version")
+
+	writeFile(t, path, "latest:^0.12\n")
+	expectRequirement(t, module, "latest:^0.12", path)
+
+	// whatever the strategy
+	t.Setenv("SIMPLE_TFSWITCH_STRATEGY", pkg.StrategyOldest)
+	if tfversion, _, err := pkg.ResolveTFProvidedModule(module, srv.source()); err != nil || tfversion != "0.12.31" {
+		t.Errorf("Expected 0.12.31 for latest:^0.12, got %s, %v (unexpected)", tfversion, err)
+	}
+
+	writeFile(t, path, "latest:^0.12[\n")
+	if _, _, err := pkg.ResolveTFProvidedModule(module, srv.source()); !errors.Is(err, pkg.ErrInvalidVersion) {
+		t.Errorf("Expected an invalid version error for an invalid regex, got %v (unexpected)", err)
+	}
+}